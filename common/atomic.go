@@ -0,0 +1,250 @@
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ResolvePath follows symlinks and returns the path of the real file.
+// A path that doesn't exist is returned as is.
+func ResolvePath(path string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+
+	return realPath, err
+}
+
+// WriteFileAtomic replaces the file at path with content produced by write.
+// Content is written to a temporary file in the same directory, synced and renamed over path,
+// then the directory is synced. The temporary file is removed on any error.
+// If info of the replaced file is given, its mode and, where permitted, owner and group are kept.
+// path must not be a symlink, resolve it with ResolvePath first.
+func WriteFileAtomic(path string, info fs.FileInfo, write func(w io.Writer) error, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	dir := filepath.Dir(path)
+
+	tmpName, err := writeTempFile(dir, 0o600, info, write, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("renaming temporary file to target", "from", tmpName, "to", path)
+
+	if err = os.Rename(tmpName, path); err != nil {
+		logger.Error("failed to rename temporary file", "from", tmpName, "to", path, "error", err)
+		removeTempFile(tmpName, logger)
+
+		return err
+	}
+
+	if err = syncDir(dir); err != nil {
+		logger.Error("failed to sync directory", "dir", dir, "error", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// CreateFileAtomic creates the file at path with content produced by write and permissions perm (before umask).
+// Content is written to a temporary file in the same directory, synced and hard-linked to path,
+// then the directory is synced, so a crash never leaves a partially written file at path.
+// An existing file is never replaced, an error matching fs.ErrExist is returned instead.
+//
+// On file systems without hard links the file is created exclusively and the content is copied into it,
+// a crash may leave it partially written then.
+func CreateFileAtomic(path string, perm fs.FileMode, write func(w io.Writer) error, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	dir := filepath.Dir(path)
+
+	tmpName, err := writeTempFile(dir, perm, nil, write, logger)
+	if err != nil {
+		return err
+	}
+
+	// The temporary name is an extra link after linking, it's removed in any case
+	defer removeTempFile(tmpName, logger)
+
+	logger.Debug("linking temporary file to target", "from", tmpName, "to", path)
+
+	if err = os.Link(tmpName, path); errors.Is(err, fs.ErrExist) {
+		logger.Debug("target already exists", "path", path)
+
+		return err
+	} else if err != nil {
+		logger.Debug("failed to link temporary file, copying it", "from", tmpName, "to", path, "error", err)
+
+		if err = copyToNewFile(tmpName, path, perm, logger); err != nil {
+			return err
+		}
+	}
+
+	if err = syncDir(dir); err != nil {
+		logger.Error("failed to sync directory", "dir", dir, "error", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// writeTempFile writes content produced by write to a new temporary file in dir created with perm,
+// syncs and closes it. Returns the name of the file, it's removed on any error.
+// If info is given, mode and, where permitted, owner and group of the file are set to the ones of info.
+func writeTempFile(
+	dir string,
+	perm fs.FileMode,
+	info fs.FileInfo,
+	write func(w io.Writer) error,
+	logger *slog.Logger,
+) (_ string, err error) {
+	logger.Debug("creating temporary file", "dir", dir)
+
+	tmp, err := createTemp(dir, perm)
+	if err != nil {
+		logger.Error("failed to create temporary file", "dir", dir, "error", err)
+
+		return "", err
+	}
+
+	tmpName := tmp.Name()
+	logger.Debug("temporary file created", "tmp_path", tmpName)
+
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+
+			removeTempFile(tmpName, logger)
+		}
+	}()
+
+	buf := bufio.NewWriter(tmp)
+
+	if err = write(buf); err != nil {
+		logger.Error("failed to write temporary file", "error", err)
+
+		return "", err
+	}
+
+	if err = buf.Flush(); err != nil {
+		logger.Error("failed to flush buffer", "error", err)
+
+		return "", err
+	}
+
+	if info != nil {
+		logger.Debug("preserving file permissions", "mode", info.Mode().Perm())
+
+		if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+			logger.Error("failed to set file permissions", "error", err)
+
+			return "", err
+		}
+
+		// Only root or the owner being a member of the group may do this
+		if chownErr := chownLike(tmp, info); errors.Is(chownErr, fs.ErrPermission) {
+			logger.Debug("not permitted to preserve file owner", "tmp_path", tmpName)
+		} else if chownErr != nil {
+			logger.Warn("failed to preserve file owner", "tmp_path", tmpName, "error", chownErr)
+		}
+	}
+
+	logger.Debug("syncing temporary file")
+
+	if err = tmp.Sync(); err != nil {
+		logger.Error("failed to sync temporary file", "error", err)
+
+		return "", err
+	}
+
+	if err = tmp.Close(); err != nil {
+		logger.Error("failed to close temporary file", "error", err)
+
+		return "", err
+	}
+
+	return tmpName, nil
+}
+
+// copyToNewFile copies the file at src to a new file at dst created with perm and syncs it.
+// The new file is removed if copying fails.
+func copyToNewFile(src, dst string, perm fs.FileMode, logger *slog.Logger) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		logger.Error("failed to open temporary file", "tmp_path", src, "error", err)
+
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		logger.Debug("failed to create file", "path", dst, "error", err)
+
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = out.Close()
+
+			if removeErr := os.Remove(dst); removeErr != nil {
+				logger.Error("failed to remove partially written file", "path", dst, "error", removeErr)
+			}
+		}
+	}()
+
+	if _, err = io.Copy(out, in); err != nil {
+		logger.Error("failed to copy temporary file", "from", src, "to", dst, "error", err)
+
+		return err
+	}
+
+	if err = out.Sync(); err != nil {
+		logger.Error("failed to sync file", "path", dst, "error", err)
+
+		return err
+	}
+
+	return out.Close()
+}
+
+// createTemp creates a new temporary file in dir with permissions perm (before umask),
+// unlike os.CreateTemp always using 0600.
+func createTemp(dir string, perm fs.FileMode) (*os.File, error) {
+	for range 10000 {
+		name := filepath.Join(dir, ".patch-"+strconv.FormatUint(rand.Uint64(), 36)+".tmp")
+
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if !errors.Is(err, fs.ErrExist) {
+			return f, err
+		}
+	}
+
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, ".patch-*.tmp"), Err: fs.ErrExist}
+}
+
+// removeTempFile removes a temporary file, logging failures.
+func removeTempFile(name string, logger *slog.Logger) {
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error("failed to remove temporary file", "tmp_path", name, "error", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"io/fs"
+	"os"
+)
+
+// chownLike does nothing, file ownership isn't preserved on this platform.
+func chownLike(*os.File, fs.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing, directories can't be synced on this platform.
+func syncDir(string) error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package common_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	errWrite := errors.New("write failed")
+
+	tests := []struct {
+		name    string
+		write   func(w io.Writer) error
+		want    string
+		wantErr error
+	}{
+		{
+			name: "replaces content",
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "new\n")
+
+				return err
+			},
+			want: "new\n",
+		},
+		{
+			name: "keeps file on error",
+			write: func(w io.Writer) error {
+				_, _ = io.WriteString(w, "partial")
+
+				return errWrite
+			},
+			want:    "old\n",
+			wantErr: errWrite,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, ".env")
+
+			if err := os.WriteFile(path, []byte("old\n"), 0o640); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = common.WriteFileAtomic(path, info, tt.write, logger)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteFileAtomic() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("file content = %q, want %q", got, tt.want)
+			}
+
+			if info, err = os.Stat(path); err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode().Perm() != 0o640 {
+				t.Errorf("file mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o640))
+			}
+
+			// No temporary files left behind
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != 1 {
+				t.Errorf("directory has %d entries, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestCreateFileAtomic(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+
+	write := func(content string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+
+			return err
+		}
+	}
+
+	if err := common.CreateFileAtomic(path, 0o600, write("new\n"), logger); err != nil {
+		t.Fatalf("CreateFileAtomic() failed: %v", err)
+	}
+
+	err := common.CreateFileAtomic(path, 0o600, write("other\n"), logger)
+	if !errors.Is(err, fs.ErrExist) {
+		t.Errorf("CreateFileAtomic() of existing file error = %v, want %v", err, fs.ErrExist)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != "new\n" {
+		t.Errorf("file content = %q, want %q", got, "new\n")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("CreateFileAtomic() left temporary files: %v", entries)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chownLike sets owner and group of f to the ones of info.
+func chownLike(f *os.File, info fs.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	var cur syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &cur); err == nil && cur.Uid == st.Uid && cur.Gid == st.Gid {
+		return nil
+	}
+
+	return f.Chown(int(st.Uid), int(st.Gid))
+}
+
+// syncDir flushes directory entries, making a rename durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -4,36 +4,220 @@ package common
 //revive:enable:var-naming
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
-// CreateBackup creates a timestamped backup of the file.
+// backupTimeFormat is the timestamp format in backup names.
+const backupTimeFormat = "20060102_150405"
+
+// BackupOptions configures backups.
+type BackupOptions struct {
+	// Directory for backups, the directory of the file if empty
+	Dir string
+	// Permissions of backups, the permissions of the file if 0
+	Mode os.FileMode
+	// Number of most recent backups to keep, older ones are removed after a backup is created.
+	// All backups are kept if 0.
+	Keep int
+}
+
+// Backup is a backup file of an env file.
+type Backup struct {
+	Path string
+	Time time.Time
+}
+
+// CreateBackup creates a timestamped backup of the file with default options.
 func CreateBackup(logger *slog.Logger, path string) error {
+	_, err := CreateBackupWithOptions(logger, path, BackupOptions{})
+
+	return err
+}
+
+// CreateBackupWithOptions creates a timestamped backup of the file
+// named "<name>.<timestamp>.bak<ext>", e.g. "app.20060102_150405.bak.env".
+// Returns the backup path, or an empty string if the file doesn't exist.
+func CreateBackupWithOptions(logger *slog.Logger, path string, opts BackupOptions) (string, error) {
 	input, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil // No backup needed for non-existent file
+			return "", nil // No backup needed for non-existent file
+		}
+
+		return "", fmt.Errorf("failed to read file for backup: %w", err)
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat file for backup: %w", err)
 		}
 
-		return fmt.Errorf("failed to read file for backup: %w", err)
+		mode = info.Mode().Perm()
+	}
+
+	prefix, suffix := backupNameParts(path)
+	backupPath := filepath.Join(backupDir(path, opts), prefix+time.Now().Format(backupTimeFormat)+suffix)
+
+	// WriteFile applies mode only to new files
+	file, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
 	}
 
-	// Generate timestamp-based backup path
-	timestamp := time.Now().Format("20060102_150405")
-	ext := filepath.Ext(path)
-	basePath := strings.TrimSuffix(path, ext)
-	backupPath := fmt.Sprintf("%s.%s.bak%s", basePath, timestamp, ext)
+	_, err = file.Write(input)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
 
-	if err := os.WriteFile(backupPath, input, 0o644); err != nil {
-		return fmt.Errorf("failed to write backup: %w", err)
+	if err = errors.Join(err, file.Close()); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
 	}
 
 	logger.Info("created backup", "backup_path", backupPath)
 
+	if opts.Keep > 0 {
+		if err := pruneBackups(logger, path, opts); err != nil {
+			return backupPath, err
+		}
+	}
+
+	return backupPath, nil
+}
+
+// ListBackups returns backups of the file, most recent first.
+// Only opts.Dir is used.
+func ListBackups(path string, opts BackupOptions) ([]Backup, error) {
+	dir := backupDir(path, opts)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	prefix, suffix := backupNameParts(path)
+
+	var backups []Backup
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+
+		t, err := time.ParseInLocation(backupTimeFormat, timestamp, time.Local)
+		if err != nil {
+			continue // another file with a similar name
+		}
+
+		backups = append(backups, Backup{Path: filepath.Join(dir, name), Time: t})
+	}
+
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return cmp.Or(b.Time.Compare(a.Time), cmp.Compare(b.Path, a.Path))
+	})
+
+	return backups, nil
+}
+
+// RestoreBackup atomically replaces the file with the content of a backup.
+// Mode and owner of the replaced file are kept, a missing file gets the mode of the backup.
+func RestoreBackup(logger *slog.Logger, path string, backup Backup) error {
+	in, err := os.Open(backup.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	defer in.Close()
+
+	realPath, err := ResolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(realPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		info, err = in.Stat()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = WriteFileAtomic(realPath, info, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+
+		return err
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	logger.Info("restored backup", "backup_path", backup.Path, "path", path)
+
+	return nil
+}
+
+// pruneBackups removes all but opts.Keep most recent backups of the file.
+func pruneBackups(logger *slog.Logger, path string, opts BackupOptions) error {
+	backups, err := ListBackups(path, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if len(backups) <= opts.Keep {
+		return nil
+	}
+
+	var errs []error
+
+	for _, backup := range backups[opts.Keep:] {
+		if err := os.Remove(backup.Path); err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		logger.Debug("removed old backup", "backup_path", backup.Path)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to remove old backups: %w", err)
+	}
+
 	return nil
 }
+
+// backupDir returns the directory for backups of the file.
+func backupDir(path string, opts BackupOptions) string {
+	if opts.Dir != "" {
+		return opts.Dir
+	}
+
+	return filepath.Dir(path)
+}
+
+// backupNameParts returns the parts of backup names before and after the timestamp.
+func backupNameParts(path string) (string, string) {
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + ".", ".bak" + ext
+}
@@ -0,0 +1,84 @@
+package common_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+func TestBackups(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	path := filepath.Join(dir, ".env")
+	opts := common.BackupOptions{Dir: backupDir, Keep: 2}
+
+	if err := os.Mkdir(backupDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("A=current\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Older backups and an unrelated file
+	for name, content := range map[string]string{
+		".20240101_100000.bak.env":    "A=oldest\n",
+		".20240102_100000.bak.env":    "A=older\n",
+		"app.20240102_100000.bak.env": "B=1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(backupDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backupPath, err := common.CreateBackupWithOptions(logger, path, opts)
+	if err != nil {
+		t.Fatalf("CreateBackupWithOptions() failed: %v", err)
+	}
+
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("backup mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	backups, err := common.ListBackups(path, opts)
+	if err != nil {
+		t.Fatalf("ListBackups() failed: %v", err)
+	}
+
+	wantPaths := []string{backupPath, filepath.Join(backupDir, ".20240102_100000.bak.env")}
+	if len(backups) != len(wantPaths) {
+		t.Fatalf("ListBackups() = %v, want paths %v", backups, wantPaths)
+	}
+
+	for i, backup := range backups {
+		if backup.Path != wantPaths[i] {
+			t.Errorf("ListBackups()[%d].Path = %q, want %q", i, backup.Path, wantPaths[i])
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(backupDir, "app.20240102_100000.bak.env")); err != nil {
+		t.Errorf("backup of another file was removed: %v", err)
+	}
+
+	if err = common.RestoreBackup(logger, path, backups[1]); err != nil {
+		t.Fatalf("RestoreBackup() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "A=older\n"; string(got) != want {
+		t.Errorf("restored content = %q, want %q", got, want)
+	}
+}
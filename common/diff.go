@@ -0,0 +1,220 @@
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DiffContext is the number of unchanged lines shown around changes in UnifiedDiff.
+const DiffContext = 3
+
+type diffOp struct {
+	kind byte // ' ' for unchanged, '-' for removed, '+' for added
+	idx  int  // Index of the line in the old text, in the new one for added lines
+}
+
+// UnifiedDiff returns a unified diff between old and new text.
+// Returns an empty string if the texts are equal.
+func UnifiedDiff(oldName, newName string, oldText, newText []byte) string {
+	return unifiedDiff(oldName, newName, oldText, newText, nil)
+}
+
+// unifiedDiff implements UnifiedDiff. Lines are compared as is,
+// but shown as show returns them if it's not nil (show must keep lines of the text).
+func unifiedDiff(oldName, newName string, oldText, newText []byte, show func(string) string) string {
+	if string(oldText) == string(newText) {
+		return ""
+	}
+
+	oldLines, newLines := splitLines(string(oldText)), splitLines(string(newText))
+	ops := diffLines(oldLines, newLines)
+
+	if show != nil {
+		oldLines, newLines = splitLines(show(string(oldText))), splitLines(show(string(newText)))
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("--- " + oldName + "\n")
+	sb.WriteString("+++ " + newName + "\n")
+
+	// Line numbers of both texts before every op
+	oldIdx := make([]int, len(ops)+1)
+	newIdx := make([]int, len(ops)+1)
+
+	for i, op := range ops {
+		oldIdx[i+1], newIdx[i+1] = oldIdx[i], newIdx[i]
+		if op.kind != '+' {
+			oldIdx[i+1]++
+		}
+
+		if op.kind != '-' {
+			newIdx[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		// Find next change
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+
+		if i == len(ops) {
+			break
+		}
+
+		start := max(0, i-DiffContext)
+
+		// Extend the hunk while the next change is close enough to share context
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+
+			if next == len(ops) || next-end > 2*DiffContext {
+				break
+			}
+
+			end = next
+		}
+
+		end = min(len(ops), end+DiffContext)
+
+		writeHunkHeader(&sb, oldIdx[start], oldIdx[end]-oldIdx[start], newIdx[start], newIdx[end]-newIdx[start])
+
+		for _, op := range ops[start:end] {
+			lines := oldLines
+			if op.kind == '+' {
+				lines = newLines
+			}
+
+			line := lines[op.idx]
+
+			sb.WriteByte(op.kind)
+			sb.WriteString(line)
+
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return sb.String()
+}
+
+func writeHunkHeader(sb *strings.Builder, oldStart, oldLen, newStart, newLen int) {
+	// Line numbers start from 1, an empty range points at the line before it
+	if oldLen > 0 {
+		oldStart++
+	}
+
+	if newLen > 0 {
+		newStart++
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+}
+
+// splitLines splits text into lines keeping line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines computes the shortest edit script between a and b (Myers' algorithm).
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+
+	v := make([]int, 2*maxD+3)
+
+	var trace [][]int
+
+search:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, slices.Clone(v))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down (insertion)
+			} else {
+				x = v[offset+k-1] + 1 // move right (deletion)
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end to collect the edit script
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{kind: ' ', idx: x - 1})
+			x--
+			y--
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			ops = append(ops, diffOp{kind: '+', idx: y - 1})
+			y--
+		} else {
+			ops = append(ops, diffOp{kind: '-', idx: x - 1})
+			x--
+		}
+	}
+
+	slices.Reverse(ops)
+
+	return ops
+}
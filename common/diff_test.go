@@ -0,0 +1,55 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "equal",
+			old:  "A=1\n",
+			new:  "A=1\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			old:  "A=1\nB=2\nC=3\n",
+			new:  "A=1\nB=20\nC=3\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n A=1\n-B=2\n+B=20\n C=3\n",
+		},
+		{
+			name: "added to empty",
+			old:  "",
+			new:  "A=1\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+A=1\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "0\n2\n3\n4\n5\n6\n7\n8\n9\n11\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+11\n",
+		},
+		{
+			name: "no newline at end",
+			old:  "A=1",
+			new:  "A=1\nB=2\n",
+			want: "--- a\n+++ b\n@@ -1,1 +1,2 @@\n-A=1\n\\ No newline at end of file\n+A=1\n+B=2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := common.UnifiedDiff("a", "b", []byte(tt.old), []byte(tt.new))
+			if got != tt.want {
+				t.Errorf("UnifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// ErrConflict is returned when a file changed after it was read to create patches.
+var ErrConflict = errors.New("file changed since it was read")
+
+// Fingerprint identifies file content.
+type Fingerprint struct {
+	Size int64
+	Hash [sha256.Size]byte
+}
+
+// NewFingerprint returns the fingerprint of data.
+func NewFingerprint(data []byte) Fingerprint {
+	return Fingerprint{
+		Size: int64(len(data)),
+		Hash: sha256.Sum256(data),
+	}
+}
+
+// ReadFingerprint returns the fingerprint of everything read from r.
+func ReadFingerprint(r io.Reader) (Fingerprint, error) {
+	h := sha256.New()
+
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	fp := Fingerprint{Size: size}
+	h.Sum(fp.Hash[:0])
+
+	return fp, nil
+}
@@ -0,0 +1,66 @@
+package common_test
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+func TestApplyPatchesIfUnchanged(t *testing.T) {
+	const content = "A=1\nB=2\n"
+
+	patches := map[int64]common.Patch{
+		1: {LineIdx: 1, ShouldInsert: true, Insert: "B=3\n", RemoveLine: true},
+	}
+
+	tests := []struct {
+		name    string
+		readAs  string // content patches were created from
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "unchanged",
+			readAs: content,
+			want:   "A=1\nB=3\n",
+		},
+		{
+			name:    "changed",
+			readAs:  "B=2\n",
+			want:    content,
+			wantErr: common.ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := common.ApplyPatchesIfUnchanged(
+				path,
+				patches,
+				common.NewFingerprint([]byte(tt.readAs)),
+				false,
+				slog.New(slog.DiscardHandler),
+			)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyPatchesIfUnchanged() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("file content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LockSuffix is appended to a file path to get the path of its lock file.
+const LockSuffix = ".lock"
+
+// lockPollInterval is how often a busy lock is retried.
+const lockPollInterval = 10 * time.Millisecond
+
+// ErrLockTimeout is returned when a lock isn't acquired in time.
+var ErrLockTimeout = errors.New("timed out waiting for file lock")
+
+// LockError is returned when a file lock cannot be acquired.
+type LockError struct {
+	Path string // path of the lock file
+	Err  error
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("failed to lock %s: %v", e.Path, e.Err)
+}
+
+func (e *LockError) Unwrap() error { return e.Err }
+
+// FileLock is an exclusive advisory lock on a file.
+// The lock is held on a sidecar file (path + LockSuffix), so the locked file can be replaced
+// with a rename while the lock is held.
+type FileLock struct {
+	file *os.File
+	path string
+}
+
+// LockFile acquires an exclusive lock on the file at path, waiting up to timeout.
+// With timeout <= 0 the lock is tried once.
+// Returns *LockError wrapping ErrLockTimeout if the lock is held by someone else.
+//
+// On most unix systems flock is used and the lock is released if the process dies.
+// Elsewhere the lock file is created exclusively and removed on Unlock,
+// a crashed process leaves it behind and it must be removed manually.
+func LockFile(path string, timeout time.Duration) (*FileLock, error) {
+	lockPath := path + LockSuffix
+	deadline := time.Now().Add(timeout)
+
+	for {
+		file, err := tryLock(lockPath)
+		if err != nil {
+			return nil, &LockError{Path: lockPath, Err: err}
+		}
+
+		if file != nil {
+			return &FileLock{file: file, path: lockPath}, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, &LockError{Path: lockPath, Err: ErrLockTimeout}
+		}
+
+		time.Sleep(min(lockPollInterval, time.Until(deadline)))
+	}
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	return unlock(l.file, l.path)
+}
@@ -0,0 +1,28 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// tryLock creates the lock file exclusively.
+// Returns nil file if it already exists.
+func tryLock(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, nil
+	}
+
+	return file, err
+}
+
+// unlock closes and removes the lock file.
+func unlock(file *os.File, path string) error {
+	return errors.Join(file.Close(), os.Remove(path))
+}
@@ -0,0 +1,42 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock opens the lock file and locks it with flock.
+// Returns nil file if the lock is held by someone else.
+func tryLock(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == nil {
+		return file, nil
+	}
+
+	_ = file.Close()
+
+	if errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EINTR) {
+		return nil, nil
+	}
+
+	return nil, err
+}
+
+// unlock releases the flock and closes the lock file.
+// The lock file is kept: removing it would let another process lock a file nobody else sees.
+func unlock(file *os.File, _ string) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	return errors.Join(err, file.Close())
+}
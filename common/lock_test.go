@@ -0,0 +1,45 @@
+package common_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+
+	lock, err := common.LockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockFile() failed: %v", err)
+	}
+
+	_, err = common.LockFile(path, 50*time.Millisecond)
+
+	var lockErr *common.LockError
+	if !errors.As(err, &lockErr) || !errors.Is(err, common.ErrLockTimeout) {
+		t.Fatalf("LockFile() on locked file error = %v, want LockError with ErrLockTimeout", err)
+	}
+
+	if lockErr.Path != path+common.LockSuffix {
+		t.Errorf("LockError.Path = %q, want %q", lockErr.Path, path+common.LockSuffix)
+	}
+
+	// Released while waiting
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = lock.Unlock()
+	}()
+
+	relock, err := common.LockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockFile() after Unlock() failed: %v", err)
+	}
+
+	if err = relock.Unlock(); err != nil {
+		t.Errorf("Unlock() failed: %v", err)
+	}
+}
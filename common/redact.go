@@ -0,0 +1,200 @@
+//revive:disable:var-naming
+package common
+
+//revive:enable:var-naming
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"slices"
+	"strings"
+)
+
+// RedactMode defines how redacted values look.
+type RedactMode uint8
+
+const (
+	// RedactMask replaces values with RedactedValue.
+	RedactMask RedactMode = iota
+	// RedactHash replaces values with a short keyed hash ("hmac:0123456789ab"),
+	// so changes are visible without revealing values.
+	// Unless RedactPolicy.HashKey is set, the key is random per process,
+	// so hashes can't be brute-forced offline, but differ between processes.
+	RedactHash
+)
+
+// RedactedValue replaces values in RedactMask mode.
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactPatterns match keys of variables that usually hold secrets.
+var DefaultRedactPatterns = []string{"*_KEY", "*_SECRET", "*PASSWORD*", "*TOKEN*"}
+
+// RedactPolicy defines values hidden from logs.
+// A value is redacted if its key matches any of the patterns or is listed in Keys.
+// An empty policy redacts nothing.
+type RedactPolicy struct {
+	// Case-insensitive patterns in path.Match syntax
+	Patterns []string
+	// Exact keys
+	Keys []string
+	Mode RedactMode
+	// HMAC-SHA256 key for RedactHash, a random per-process key if empty.
+	// Set it to compare hashes across processes, keep it secret.
+	HashKey []byte
+}
+
+// processHashKey is the HMAC key of RedactHash if RedactPolicy.HashKey is empty.
+var processHashKey = rand.Text()
+
+// DefaultRedactPolicy returns a policy masking values of keys matching DefaultRedactPatterns.
+func DefaultRedactPolicy() *RedactPolicy {
+	return &RedactPolicy{Patterns: slices.Clone(DefaultRedactPatterns)}
+}
+
+// Matches reports whether the value of key must be redacted.
+func (p *RedactPolicy) Matches(key string) bool {
+	if p == nil {
+		return false
+	}
+
+	if slices.Contains(p.Keys, key) {
+		return true
+	}
+
+	upper := strings.ToUpper(key)
+	for _, pattern := range p.Patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), upper); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value returns the value to log for key.
+func (p *RedactPolicy) Value(key, value string) string {
+	if !p.Matches(key) {
+		return value
+	}
+
+	return p.redact(value)
+}
+
+// Content redacts values of matching variables in env file content.
+// Continuation lines of multiline quoted values are redacted too.
+func (p *RedactPolicy) Content(content string) string {
+	if p == nil || content == "" {
+		return content
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+
+	var quote byte // quote of an open multiline value being redacted
+
+	for i, line := range lines {
+		if quote != 0 {
+			body, ending := splitLineEnding(line)
+			if closeIdx := closingQuote(body, quote); closeIdx >= 0 {
+				quote = 0
+				lines[i] = p.redact(body[:closeIdx]) + body[closeIdx:] + ending
+			} else if body != "" {
+				lines[i] = p.redact(body) + ending
+			}
+
+			continue
+		}
+
+		key, valueStart, ok := lineKey(line)
+		if !ok || !p.Matches(key) {
+			continue
+		}
+
+		body, ending := splitLineEnding(line[valueStart:])
+		if body == "" {
+			continue
+		}
+
+		// Keep quotes, so the line still reads as an assignment
+		if q := body[0]; q == '"' || q == '\'' || q == '`' {
+			if closeIdx := closingQuote(body[1:], q); closeIdx >= 0 {
+				body = string(q) + p.redact(body[1:closeIdx+1]) + body[closeIdx+1:]
+			} else {
+				quote = q
+				body = string(q) + p.redact(body[1:])
+			}
+		} else {
+			body = p.redact(body)
+		}
+
+		lines[i] = line[:valueStart] + body + ending
+	}
+
+	return strings.Join(lines, "")
+}
+
+// Diff returns UnifiedDiff of the texts with values redacted like Content does.
+// Lines are compared before redaction, so a changed secret is still shown as a changed line.
+func (p *RedactPolicy) Diff(oldName, newName string, oldText, newText []byte) string {
+	return unifiedDiff(oldName, newName, oldText, newText, p.Content)
+}
+
+// redact returns the redacted form of a value.
+func (p *RedactPolicy) redact(value string) string {
+	if p.Mode == RedactHash {
+		key := p.HashKey
+		if len(key) == 0 {
+			key = []byte(processHashKey)
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:6])
+	}
+
+	return RedactedValue
+}
+
+// closingQuote returns the index of the quote closing a value in s, or -1.
+// Escaped quotes are skipped for every quote type, as in the default dialect.
+// For dialects where a backslash doesn't escape the quote this redacts too much rather than leaking the value.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lineKey returns the key of an assignment line and the index its value starts at.
+// Commented out assignments count too.
+func lineKey(line string) (string, int, bool) {
+	equalIdx := strings.IndexByte(line, '=')
+	if equalIdx < 0 {
+		return "", 0, false
+	}
+
+	key := strings.TrimLeft(line[:equalIdx], "# \t")
+	key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+
+	if key == "" {
+		return "", 0, false
+	}
+
+	return key, equalIdx + 1, true
+}
+
+// splitLineEnding splits a line into its body and line ending.
+func splitLineEnding(line string) (string, string) {
+	body := strings.TrimRight(line, "\r\n")
+
+	return body, line[len(body):]
+}
@@ -0,0 +1,109 @@
+package common_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+func TestRedactPolicyContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *common.RedactPolicy
+		content string
+		want    string
+	}{
+		{
+			name:    "default patterns",
+			policy:  common.DefaultRedactPolicy(),
+			content: "API_KEY=abc\nDB_PASSWORD='p@ss'\nauth_token=\"t\"\nHOST=localhost\n",
+			want:    "API_KEY=[REDACTED]\nDB_PASSWORD='[REDACTED]'\nauth_token=\"[REDACTED]\"\nHOST=localhost\n",
+		},
+		{
+			name:    "explicit keys",
+			policy:  &common.RedactPolicy{Keys: []string{"DSN"}},
+			content: "export DSN=postgres://u:p@h/db\nAPI_KEY=abc\n",
+			want:    "export DSN=[REDACTED]\nAPI_KEY=abc\n",
+		},
+		{
+			name:    "multiline value",
+			policy:  common.DefaultRedactPolicy(),
+			content: "TLS_KEY=\"-----BEGIN\nsecret\n-----END\" # cert\nA=1\n",
+			want:    "TLS_KEY=\"[REDACTED]\n[REDACTED]\n[REDACTED]\" # cert\nA=1\n",
+		},
+		{
+			name:    "escaped quote",
+			policy:  common.DefaultRedactPolicy(),
+			content: "APP_SECRET=\"a\\\"b\"\n",
+			want:    "APP_SECRET=\"[REDACTED]\"\n",
+		},
+		{
+			name:    "multiline value with escaped single quote",
+			policy:  common.DefaultRedactPolicy(),
+			content: "API_KEY='abc\\'\nsecret-line2'\nA=1\n",
+			want:    "API_KEY='[REDACTED]\n[REDACTED]'\nA=1\n",
+		},
+		{
+			name:    "commented out assignment",
+			policy:  common.DefaultRedactPolicy(),
+			content: "# APP_SECRET=abc\n",
+			want:    "# APP_SECRET=[REDACTED]\n",
+		},
+		{
+			name:    "hash",
+			policy:  &common.RedactPolicy{Keys: []string{"A"}, Mode: common.RedactHash, HashKey: []byte("test")},
+			content: "A=1\n",
+			want:    "A=hmac:13c779faa1ef\n",
+		},
+		{
+			name:    "nil policy",
+			content: "API_KEY=abc\n",
+			want:    "API_KEY=abc\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Content(tt.content); got != tt.want {
+				t.Errorf("Content() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactPolicyValue(t *testing.T) {
+	policy := common.DefaultRedactPolicy()
+
+	if got := policy.Value("STRIPE_SECRET", "sk_live"); strings.Contains(got, "sk_live") {
+		t.Errorf("Value() = %q, want redacted", got)
+	}
+
+	if got := policy.Value("PORT", "8080"); got != "8080" {
+		t.Errorf("Value() = %q, want %q", got, "8080")
+	}
+}
+
+func TestRedactPolicyHashKey(t *testing.T) {
+	policy := &common.RedactPolicy{Keys: []string{"PIN"}, Mode: common.RedactHash}
+
+	first, second := policy.Value("PIN", "1234"), policy.Value("PIN", "1234")
+	if first != second || strings.Contains(first, "1234") {
+		t.Errorf("Value() = %q, %q, want equal redacted hashes", first, second)
+	}
+
+	policy.HashKey = []byte("test")
+	if got := policy.Value("PIN", "1234"); got == first {
+		t.Errorf("Value() with HashKey = %q, want a hash different from the per-process key", got)
+	}
+}
+
+func TestRedactPolicyDiff(t *testing.T) {
+	policy := common.DefaultRedactPolicy()
+
+	got := policy.Diff("a", "b", []byte("API_KEY=old\nPORT=1\n"), []byte("API_KEY=new\nPORT=2\n"))
+
+	want := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-API_KEY=[REDACTED]\n-PORT=1\n+API_KEY=[REDACTED]\n+PORT=2\n"
+	if got != want {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+}
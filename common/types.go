@@ -37,20 +37,26 @@ const (
 
 // VariableData contains parsed variable information.
 type VariableData struct {
-	Key          string
-	Value        string
-	Prefix       string // Everything before the value (export, whitespace, key, =, etc)
-	Suffix       string // Everything after the value (whitespace, comments)
-	IsTerminated bool
-	IsQuoted     bool
-	Quote        byte
+	Key           string
+	Value         string // Value with escape sequences decoded
+	RawValue      string // Value as written in the file (without quotes)
+	Prefix        string // Everything before the value (export, whitespace, key, =, etc)
+	Suffix        string // Everything after the value (whitespace, comments)
+	InlineComment string // Text of the inline comment in Suffix, without '#'
+	IsTerminated  bool
+	IsQuoted      bool
+	Quote         byte
+	// Key without "=" (parser.Config.AllowBareKeys), the value is inherited from the environment
+	IsBare bool
 }
 
 type VariableValPartData struct {
-	Value        string // What value did variable have
-	Suffix       string // Everything after the value (whitespace, comments)
-	IsTerminated bool   // If variable was terminated on that line
-	Quote        byte
+	Value         string // What value did variable have (escape sequences decoded)
+	RawValue      string // Value part as written in the file
+	Suffix        string // Everything after the value (whitespace, comments)
+	InlineComment string // Text of the inline comment in Suffix, without '#'
+	IsTerminated  bool   // If variable was terminated on that line
+	Quote         byte
 }
 
 type SectionData struct {
@@ -10,7 +10,6 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"slices"
 )
 
@@ -231,8 +230,67 @@ func ProcessPatches(
 	return nil
 }
 
+// PatchStream applies patches to content of in and writes the result to w.
+// in is read from the start twice: to scan line offsets and to copy the content.
+func PatchStream(in io.ReadSeeker, w io.Writer, patches map[int64]Patch, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	size, err := in.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	logger.Debug("scanning line offsets")
+
+	spans, err := ScanLineOffsetsReader(bufio.NewReader(in), patches, logger)
+	if err != nil {
+		logger.Error("failed to scan line offsets", "error", err)
+
+		return err
+	}
+
+	logger.Debug("line offsets scanned successfully", "span_count", len(spans))
+
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return ProcessPatches(in, size, w, spans, patches, logger)
+}
+
 // Orchestrator: reads spans, streams file, applies patches, writes temp, renames.
 func ApplyPatches(path string, patches map[int64]Patch, autoNewLine bool, logger *slog.Logger) error {
+	return applyPatches(path, patches, autoNewLine, nil, logger)
+}
+
+// ApplyPatchesIfUnchanged works like ApplyPatches,
+// but fails with ErrConflict if the file content doesn't match the fingerprint
+// of the content the patches were created from.
+// Content is checked on the same open file the patches are applied to.
+func ApplyPatchesIfUnchanged(
+	path string,
+	patches map[int64]Patch,
+	expected Fingerprint,
+	autoNewLine bool,
+	logger *slog.Logger,
+) error {
+	return applyPatches(path, patches, autoNewLine, &expected, logger)
+}
+
+// applyPatches implements ApplyPatches, checks the content if expected isn't nil.
+func applyPatches(
+	path string,
+	patches map[int64]Patch,
+	autoNewLine bool,
+	expected *Fingerprint,
+	logger *slog.Logger,
+) error {
 	if logger == nil {
 		logger = slog.Default()
 	}
@@ -256,22 +314,18 @@ func ApplyPatches(path string, patches map[int64]Patch, autoNewLine bool, logger
 		}
 	}
 
-	logger.Debug("scanning line offsets")
-
-	spans, err := ScanLineOffsets(path, patches, logger)
+	realPath, err := ResolvePath(path)
 	if err != nil {
-		logger.Error("failed to scan line offsets", "error", err)
+		logger.Error("failed to resolve path", "path", path, "error", err)
 
 		return err
 	}
 
-	logger.Debug("line offsets scanned successfully", "span_count", len(spans))
-
-	logger.Debug("opening input file", "path", path)
+	logger.Debug("opening input file", "path", realPath)
 
-	in, err := os.Open(path)
+	in, err := os.Open(realPath)
 	if err != nil {
-		logger.Error("failed to open input file", "path", path, "error", err)
+		logger.Error("failed to open input file", "path", realPath, "error", err)
 
 		return err
 	}
@@ -280,83 +334,38 @@ func ApplyPatches(path string, patches map[int64]Patch, autoNewLine bool, logger
 
 	info, err := in.Stat()
 	if err != nil {
-		logger.Error("failed to stat input file", "path", path, "error", err)
+		logger.Error("failed to stat input file", "path", realPath, "error", err)
 
 		return err
 	}
 
 	logger.Debug("input file info", "size", info.Size(), "mode", info.Mode())
 
-	tmpDir := filepath.Dir(path)
-	logger.Debug("creating temporary file", "dir", tmpDir)
-
-	tmp, err := os.CreateTemp(tmpDir, ".patch-*.tmp")
-	if err != nil {
-		logger.Error("failed to create temporary file", "dir", tmpDir, "error", err)
-
-		return err
-	}
-
-	tmpName := tmp.Name()
-	logger.Debug("temporary file created", "tmp_path", tmpName)
-
-	buf := bufio.NewWriter(tmp)
-
-	logger.Info("processing patches")
-
-	if err := ProcessPatches(
-		in,
-		info.Size(),
-		buf,
-		spans,
-		patches,
-		logger,
-	); err != nil {
-		logger.Error("failed to process patches", "error", err)
-
-		return err
-	}
-
-	logger.Debug("patches processed successfully")
-
-	logger.Debug("flushing buffer")
-
-	if err := buf.Flush(); err != nil {
-		logger.Error("failed to flush buffer", "error", err)
-
-		return err
-	}
-
-	logger.Debug("syncing temporary file")
-
-	if err := tmp.Sync(); err != nil {
-		logger.Error("failed to sync temporary file", "error", err)
-
-		return err
-	}
-
-	if st, err := os.Stat(path); err == nil {
-		logger.Debug("preserving file permissions", "mode", st.Mode())
-		_ = tmp.Chmod(st.Mode())
-	}
+	if expected != nil {
+		fp, err := ReadFingerprint(in)
+		if err != nil {
+			logger.Error("failed to read input file", "path", realPath, "error", err)
 
-	logger.Debug("closing temporary file")
+			return err
+		}
 
-	if err := tmp.Close(); err != nil {
-		logger.Error("failed to close temporary file", "error", err)
+		if fp != *expected {
+			logger.Warn("file changed since patches were created", "path", realPath)
 
-		return err
+			return fmt.Errorf("%s: %w", path, ErrConflict)
+		}
 	}
 
-	logger.Debug("renaming temporary file to target", "from", tmpName, "to", path)
-
-	if err := os.Rename(tmpName, path); err != nil {
-		logger.Error("failed to rename temporary file", "from", tmpName, "to", path, "error", err)
+	logger.Info("processing patches")
 
+	err = WriteFileAtomic(realPath, info, func(w io.Writer) error {
+		return PatchStream(in, w, patches, logger)
+	}, logger)
+	if err != nil {
 		return err
 	}
 
-	logger.Info("patch application completed successfully", "path", path)
+	logger.Info("patch application completed successfully", "path", realPath)
 
 	return nil
 }
@@ -2,10 +2,13 @@ package envfile
 
 import (
 	"bufio"
-	"log"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
 	"log/slog"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/safeblock-dev/werr"
 
@@ -15,57 +18,358 @@ import (
 )
 
 type UpdateFileOptions struct {
-	Backup               bool
-	Logger               *slog.Logger
+	// Creates a timestamped backup before updating (UpdateFile only)
+	Backup bool
+	// Location, permissions and retention of backups
+	BackupOptions common.BackupOptions
+	Logger        *slog.Logger
+	// Values hidden from log output and UpdateResult.Diff, common.DefaultRedactPolicy if nil.
+	// Use an empty policy to show all values.
+	Redact               *common.RedactPolicy
 	SectionStartComments map[string]string
 	SectionEndComments   map[string]string
+	// Order of variables and sections added to the file
+	Order updater.PlacementOrder
+	// What updates may do with existing and missing variables, all changes are allowed if 0
+	Mode updater.UpdateMode
+	// How the file is read and values are written, parser.DialectDefault if nil
+	Dialect *parser.Dialect
+	// Accept lines with a key only ("KEY"), see parser.SetAllowBareKeys
+	AllowBareKeys bool
+	// Only compute the changes: nothing is written, no backups or temporary files are created.
+	// UpdateResult.Diff contains a unified diff of the changes.
+	DryRun bool
+	// Create the file if it doesn't exist (UpdateFile only)
+	Create bool
+	// Permissions of a created file before umask, DefaultCreatePerm if 0
+	CreatePerm os.FileMode
+	// How long UpdateFile waits for the file lock, DefaultLockTimeout if 0.
+	// If negative, the lock is tried once without waiting.
+	LockTimeout time.Duration
+	// Don't lock the file in UpdateFile
+	NoLock bool
+	// How many times UpdateFile starts over if the file changes while it's updated
+	// (possible when it's modified by something not using the lock).
+	// If 0 or exhausted, an error wrapping common.ErrConflict is returned.
+	ConflictRetries int
 }
 
+const (
+	// DefaultCreatePerm is the permissions of files created by UpdateFile.
+	DefaultCreatePerm os.FileMode = 0o600
+	// DefaultLockTimeout is how long UpdateFile waits for the file lock by default.
+	DefaultLockTimeout = 10 * time.Second
+)
+
+// UpdateResult describes the outcome of an update.
+type UpdateResult struct {
+	// Whether the content was changed (or would be changed in dry-run mode)
+	Changed bool
+	// Unified diff of the original and the updated content with values redacted (see Redact),
+	// only set in dry-run mode
+	Diff string
+	// What happened to every updated key
+	Changes updater.ChangeSet
+}
+
+// UpdateFile applies updates to the env file at path.
+// Unless NoLock is set, an exclusive advisory lock (see common.LockFile) is held
+// from reading the file till the updated content replaces it,
+// so concurrent UpdateFile calls don't drop each other's changes.
+// Returns *common.LockError if the lock can't be acquired.
+// Patches are applied only if the file content didn't change since it was read,
+// otherwise the update is retried (see ConflictRetries) or fails with common.ErrConflict.
 func UpdateFile(
 	path string,
 	updates []updater.Update,
 	opts UpdateFileOptions,
-) error {
+) (UpdateResult, error) {
 	if opts.Logger == nil {
 		opts.Logger = slog.Default()
 	}
 
+	if opts.DryRun {
+		res, err := dryRunFile(path, updates, opts)
+
+		return res, withPath(err, path)
+	}
+
+	// Callers going through different symlinks must share the lock
+	realPath, err := common.ResolvePath(path)
+	if err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "failed to resolve path %q", path)
+	}
+
+	if !opts.NoLock {
+		timeout := opts.LockTimeout
+		if timeout == 0 {
+			timeout = DefaultLockTimeout
+		}
+
+		lock, err := common.LockFile(realPath, timeout)
+		if err != nil {
+			return UpdateResult{}, werr.Wrap(err)
+		}
+
+		defer func() {
+			if err := lock.Unlock(); err != nil {
+				opts.Logger.Error("failed to unlock file", "path", path, "error", err)
+			}
+		}()
+	}
+
 	if opts.Backup {
-		err := common.CreateBackup(opts.Logger, path)
+		_, err := common.CreateBackupWithOptions(opts.Logger, path, opts.BackupOptions)
 		if err != nil {
-			return werr.Wrapf(err, "error trying to create backup for file %q", path)
+			return UpdateResult{}, werr.Wrapf(err, "error trying to create backup for file %q", path)
 		}
 	}
 
+	for attempt := 0; ; attempt++ {
+		res, err := updateFile(realPath, updates, opts)
+		if errors.Is(err, common.ErrConflict) && attempt < opts.ConflictRetries {
+			opts.Logger.Warn("file changed while updating, retrying", "path", path, "attempt", attempt+1)
+
+			continue
+		}
+
+		return res, withPath(err, path)
+	}
+}
+
+// beforeApply is called between reading the file and applying patches to it in updateFile,
+// tests use it to change the file meanwhile.
+var beforeApply func(path string)
+
+// updateFile reads the file, creates patches and applies them
+// if the file didn't change since it was read.
+func updateFile(path string, updates []updater.Update, opts UpdateFileOptions) (UpdateResult, error) {
+	data, err := os.ReadFile(path)
+	if opts.Create && errors.Is(err, fs.ErrNotExist) {
+		return createFile(path, updates, opts)
+	}
+
+	if err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "error trying to read file %q", path)
+	}
+
+	patches, changes, err := createPatches(bytes.NewReader(data), updates, opts)
+	if err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "failed to create patches %q", path)
+	}
+
+	if beforeApply != nil {
+		beforeApply(path)
+	}
+
+	err = common.ApplyPatchesIfUnchanged(path, patches, common.NewFingerprint(data), false, opts.Logger)
+	if err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "failed to apply patched %q", path)
+	}
+
+	return UpdateResult{Changed: len(patches) > 0, Changes: changes}, nil
+}
+
+// createFile writes updates into a new file as if they were added to an empty one.
+// The file is created atomically (see common.CreateFileAtomic).
+func createFile(path string, updates []updater.Update, opts UpdateFileOptions) (UpdateResult, error) {
+	var buf bytes.Buffer
+
+	res, err := updateStream(bytes.NewReader(nil), &buf, path, updates, opts)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	perm := opts.CreatePerm
+	if perm == 0 {
+		perm = DefaultCreatePerm
+	}
+
+	// Fails if the file was created in the meantime instead of overwriting it
+	err = common.CreateFileAtomic(path, perm, func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+
+		return err
+	}, opts.Logger)
+	if errors.Is(err, fs.ErrExist) {
+		return UpdateResult{}, werr.Wrapf(common.ErrConflict, "file %q was created concurrently", path)
+	}
+
+	if err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "failed to create file %q", path)
+	}
+
+	opts.Logger.Info("created file", "path", path, "mode", perm)
+
+	res.Changed = true
+
+	return res, nil
+}
+
+// dryRunFile computes changes UpdateFile would make without writing anything.
+func dryRunFile(path string, updates []updater.Update, opts UpdateFileOptions) (UpdateResult, error) {
 	file, err := os.Open(path)
+	if opts.Create && errors.Is(err, fs.ErrNotExist) {
+		return updateStream(bytes.NewReader(nil), io.Discard, path, updates, opts)
+	}
+
 	if err != nil {
-		return werr.Wrapf(err, "error trying to open file %q", path)
+		return UpdateResult{}, werr.Wrapf(err, "error trying to open file %q", path)
 	}
+	defer file.Close()
 
-	p := parser.NewFileParser(nil, bufio.NewReader(file), false, parser.SetLogger(opts.Logger))
+	return updateStream(file, io.Discard, path, updates, opts)
+}
+
+// UpdateBytes applies updates to env file content and returns the updated content.
+// Works like UpdateFile without touching the filesystem, Backup option is ignored.
+// In dry-run mode data is returned as is, the changes are described by UpdateResult.
+func UpdateBytes(
+	data []byte,
+	updates []updater.Update,
+	opts UpdateFileOptions,
+) ([]byte, UpdateResult, error) {
+	var buf bytes.Buffer
 
-	patches, err := updater.FromStream(p,
-		updates,
+	res, err := UpdateStream(bytes.NewReader(data), &buf, updates, opts)
+	if err != nil {
+		return nil, UpdateResult{}, err
+	}
+
+	if opts.DryRun {
+		return data, res, nil
+	}
+
+	return buf.Bytes(), res, nil
+}
+
+// UpdateStream reads env file content from in, applies updates and writes the updated content to w.
+// Works like UpdateFile without touching the filesystem, Backup option is ignored.
+// In dry-run mode nothing is written to w.
+// in is read from the start twice: to create patches and to apply them.
+func UpdateStream(
+	in io.ReadSeeker,
+	w io.Writer,
+	updates []updater.Update,
+	opts UpdateFileOptions,
+) (UpdateResult, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return updateStream(in, w, "", updates, opts)
+}
+
+// updateStream implements UpdateStream, name is used for diff headers.
+func updateStream(
+	in io.ReadSeeker,
+	w io.Writer,
+	name string,
+	updates []updater.Update,
+	opts UpdateFileOptions,
+) (UpdateResult, error) {
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return UpdateResult{}, werr.Wrap(err)
+	}
+
+	patches, changes, err := createPatches(in, updates, opts)
+	if err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "failed to create patches")
+	}
+
+	if !opts.DryRun {
+		err = common.PatchStream(in, w, patches, opts.Logger)
+		if err != nil {
+			return UpdateResult{}, werr.Wrapf(err, "failed to apply patches")
+		}
+
+		return UpdateResult{Changed: len(patches) > 0, Changes: changes}, nil
+	}
+
+	var patched bytes.Buffer
+
+	if err = common.PatchStream(in, &patched, patches, opts.Logger); err != nil {
+		return UpdateResult{}, werr.Wrapf(err, "failed to apply patches")
+	}
+
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return UpdateResult{}, werr.Wrap(err)
+	}
+
+	original, err := io.ReadAll(in)
+	if err != nil {
+		return UpdateResult{}, werr.Wrap(err)
+	}
+
+	diff := redactPolicy(opts).Diff(
+		defaultString(name, "original"),
+		defaultString(name, "updated"),
+		original,
+		patched.Bytes(),
+	)
+
+	opts.Logger.Info("dry run", "changed", diff != "")
+
+	return UpdateResult{
+		Changed: diff != "",
+		Diff:    diff,
+		Changes: changes,
+	}, nil
+}
+
+// createPatches parses env file content from r and creates patches for the updates.
+func createPatches(
+	r io.Reader,
+	updates []updater.Update,
+	opts UpdateFileOptions,
+) (map[int64]common.Patch, updater.ChangeSet, error) {
+	dialect := parser.DialectDefault
+	if opts.Dialect != nil {
+		dialect = *opts.Dialect
+	}
+
+	p := parser.NewFileParser(nil, bufio.NewReader(r), false,
+		parser.SetLogger(opts.Logger),
+		parser.SetDialect(dialect),
+		parser.SetAllowBareKeys(opts.AllowBareKeys),
+	)
+
+	options := []updater.Option{
 		updater.SetLogger(opts.Logger),
+		updater.SetDialect(dialect),
 		updater.SetSectionStartComments(opts.SectionStartComments),
-		updater.SetSectionEndComments(opts.SectionStartComments),
-	)
-	if err != nil {
-		return werr.Wrapf(err, "failed to create patches %q", path)
+		updater.SetSectionEndComments(opts.SectionEndComments),
+		updater.SetOrder(opts.Order),
+	}
+
+	if opts.Mode != 0 {
+		options = append(options, updater.SetMode(opts.Mode))
 	}
 
-	if err = file.Close(); err != nil {
-		return werr.Wrapf(err, "failed to close file %q", path)
+	patches, changes, err := updater.FromStream(p, updates, options...)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	opts.Logger.Info("patches summary", "count", len(patches))
 
+	redact := redactPolicy(opts)
+
+	for _, change := range changes {
+		opts.Logger.Debug("change",
+			"key", change.Key,
+			"kind", change.Kind,
+			"old_line", change.OldLine,
+			"new_line", change.NewLine,
+		)
+	}
+
 	for i, patch := range patches {
 		if patch.ShouldInsert {
 			opts.Logger.Debug(
 				"patch insert before",
 				"patch", i,
-				"content", patch.Insert,
+				"content", redact.Content(patch.Insert),
 				"length", len(patch.Insert),
 			)
 		}
@@ -74,15 +378,22 @@ func UpdateFile(
 			opts.Logger.Debug(
 				"patch insert after",
 				"patch", i,
-				"content", patch.InsertAfter,
+				"content", redact.Content(patch.InsertAfter),
 				"length", len(patch.InsertAfter),
 			)
 		}
 	}
 
-	err = common.ApplyPatches(path, patches, false, opts.Logger)
+	return patches, changes, nil
+}
+
+// redactPolicy returns the policy of values hidden from logs and diffs.
+func redactPolicy(opts UpdateFileOptions) *common.RedactPolicy {
+	if opts.Redact == nil {
+		return common.DefaultRedactPolicy()
+	}
 
-	return werr.Wrapf(err, "failed to apply patched %q", path)
+	return opts.Redact
 }
 
 // Alias for creating parser.
@@ -91,6 +402,7 @@ func NewParser(opts ...parser.Option) *parser.Parser {
 }
 
 // Parse everything from a scanner to an array or parsed lines.
+// Returns *parser.ParseError if a line can't be parsed or the last quoted value isn't closed.
 func Parse(p common.Parser, s *bufio.Scanner) ([]common.ParsedLine, error) {
 	lines := make([]common.ParsedLine, 0)
 
@@ -103,81 +415,63 @@ func Parse(p common.Parser, s *bufio.Scanner) ([]common.ParsedLine, error) {
 		lines = append(lines, line)
 	}
 
+	if err := s.Err(); err != nil {
+		return nil, werr.Wrap(err)
+	}
+
+	if err := unterminatedError(lines); err != nil {
+		return nil, err
+	}
+
 	return lines, nil
 }
 
+// ParseFile parses the file at filePath, see Parse.
+// Returned *parser.ParseError has the Path set.
 func ParseFile(filePath string, p common.Parser) ([]common.ParsedLine, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, werr.Wrapf(err, "error trying to open file %q", filePath)
 	}
 	defer file.Close()
 
-	return Parse(p, bufio.NewScanner(file))
-}
-
-// LinesToVariableMap converts an array of ParsedLine into a map of variable key-value pairs.
-// It handles multiline variables by accumulating unterminated values across lines.
-func LinesToVariableMap(lines []common.ParsedLine) map[string]string {
-	result := make(map[string]string)
-
-	var (
-		currentKey   string
-		currentValue strings.Builder
-		inMultiline  bool
-	)
-
-	for _, line := range lines {
-		switch line.Type {
-		case common.LineTypeVar:
-			// If we were building a multiline variable, finalize it
-			if inMultiline && currentKey != "" {
-				result[currentKey] = currentValue.String()
-				currentValue.Reset()
+	lines, err := Parse(p, bufio.NewScanner(file))
 
-				inMultiline = false
-			}
+	return lines, withPath(err, filePath)
+}
 
-			// Start new variable
-			if line.Variable != nil {
-				currentKey = line.Variable.Key
-				currentValue.WriteString(line.Variable.Value)
-
-				if line.Variable.IsTerminated {
-					// Single-line variable, store immediately
-					result[currentKey] = currentValue.String()
-					currentValue.Reset()
-
-					currentKey = ""
-				} else {
-					// Multiline variable starts
-					inMultiline = true
-				}
+// unterminatedError returns *parser.ParseError if lines end inside a quoted value.
+func unterminatedError(lines []common.ParsedLine) error {
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+
+		switch {
+		case line.Type == common.LineTypeVal && line.VariableValPart != nil && line.VariableValPart.IsTerminated:
+			return nil
+		case line.Type == common.LineTypeVar && line.Variable != nil:
+			if line.Variable.IsTerminated {
+				return nil
 			}
 
-		case common.LineTypeVal:
-			// Continuation of a multiline variable
-			if inMultiline && line.VariableValPart != nil {
-				// Add newline before appending next part (preserve multiline format)
-				currentValue.WriteString("\n")
-				currentValue.WriteString(line.VariableValPart.Value)
-
-				if line.VariableValPart.IsTerminated {
-					// Multiline variable ends
-					result[currentKey] = currentValue.String()
-					currentValue.Reset()
-
-					currentKey = ""
-					inMultiline = false
-				}
+			return &parser.ParseError{
+				Line:   i + 1,
+				Column: len(line.Variable.Prefix) + 1,
+				Text:   line.RawLine,
+				Kind:   parser.ErrUnterminatedQuote,
+				Detail: "value of " + line.Variable.Key,
 			}
 		}
 	}
 
-	// Handle case where file ends with unterminated variable
-	if inMultiline && currentKey != "" {
-		result[currentKey] = currentValue.String()
+	return nil
+}
+
+// withPath sets the path of a *parser.ParseError in err.
+func withPath(err error, path string) error {
+	var parseErr *parser.ParseError
+	if errors.As(err, &parseErr) && parseErr.Path == "" {
+		parseErr.Path = path
 	}
 
-	return result
+	return err
 }
@@ -0,0 +1,6 @@
+package envfile
+
+// SetBeforeApply sets the function called between reading a file and applying patches to it in UpdateFile.
+func SetBeforeApply(f func(path string)) {
+	beforeApply = f
+}
@@ -0,0 +1,139 @@
+package envfile
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// formatValue converts v into a string that setValue can parse back.
+// Nil pointers and interfaces are formatted as an empty string,
+// types without a known format and failing encoding.TextMarshaler implementations are formatted with fmt.Sprint.
+func formatValue(v reflect.Value, sep string) string {
+	if !v.IsValid() {
+		return ""
+	}
+
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+
+		if !v.Type().Implements(textMarshalerType) {
+			return formatValue(v.Elem(), sep)
+		}
+	}
+
+	if marshaler, ok := asTextMarshaler(v); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+
+		return sprintValue(v)
+	}
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Slice, reflect.Array:
+		return formatList(v, sep)
+	case reflect.Map:
+		return formatMap(v, sep)
+	default:
+		return sprintValue(v)
+	}
+}
+
+// sprintValue formats v with fmt.Sprint.
+func sprintValue(v reflect.Value) string {
+	if !v.CanInterface() {
+		return ""
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// asTextMarshaler returns v (or a pointer to it) as encoding.TextMarshaler if implemented.
+func asTextMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		return v.Addr().Interface().(encoding.TextMarshaler), true
+	}
+
+	return nil, false
+}
+
+// formatList joins formatted items of slice or array v with sep.
+func formatList(v reflect.Value, sep string) string {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return string(v.Bytes())
+	}
+
+	items := make([]string, v.Len())
+	for i := range v.Len() {
+		items[i] = quoteItem(formatValue(v.Index(i), sep), sep, false)
+	}
+
+	return strings.Join(items, sep)
+}
+
+// formatMap joins "key:value" entries of map v with sep, sorted by key.
+func formatMap(v reflect.Value, sep string) string {
+	items := make([]string, 0, v.Len())
+
+	iter := v.MapRange()
+	for iter.Next() {
+		key := quoteItem(formatValue(iter.Key(), sep), sep, true)
+		val := quoteItem(formatValue(iter.Value(), sep), sep, false)
+
+		items = append(items, key+mapKeyValueSep+val)
+	}
+
+	slices.Sort(items)
+
+	return strings.Join(items, sep)
+}
+
+// quoteItem quotes a slice item, a map key or a map value with strconv.Quote
+// if splitList would split or trim it otherwise: if it contains sep (or ':' for map keys),
+// starts with a double quote or has surrounding whitespace.
+func quoteItem(s, sep string, isMapKey bool) string {
+	if strings.TrimSpace(s) == s && !strings.HasPrefix(s, `"`) && !strings.Contains(s, sep) &&
+		(!isMapKey || !strings.Contains(s, mapKeyValueSep)) {
+		return s
+	}
+
+	return strconv.Quote(s)
+}
+
+// isEmptyValue reports whether v is empty for the "omitempty" and "default" tags:
+// zero values, nil pointers and empty slices, maps and strings.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
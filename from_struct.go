@@ -1,8 +1,8 @@
 package envfile
 
 import (
-	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/4nd3r5on/go-envfile/common"
 	"github.com/4nd3r5on/go-envfile/updater"
@@ -14,6 +14,13 @@ import (
 // - sectionTag (default "section"): specifies the configuration section
 // If the env tag is absent, field names are converted to UPPER_SNAKE_CASE.
 // Fields tagged with "-" are skipped.
+//
+// Values are formatted so Unmarshal can read them back: encoding.TextMarshaler is honored,
+// slice items and map entries are joined with "," (or the "sep" tag),
+// items containing the separator are written in Go double quotes (strconv.Quote),
+// other types are formatted with fmt.Sprint. Nil pointers are skipped.
+// The env tag accepts the "omitempty" option (`env:"NAME,omitempty"`) to skip empty fields,
+// the "default" tag provides a value written instead of an empty one.
 func UpdatesFromStruct(
 	data any,
 	envNameTag, sectionTag string,
@@ -88,24 +95,79 @@ func walkStruct(v reflect.Value, visitor func(reflectField)) {
 
 // fieldToUpdate converts a struct field to an Update, or returns nil if skipped
 func fieldToUpdate(field reflectField, envNameTag, sectionTag string) *updater.Update {
-	// Determine environment variable name
-	key := field.typ.Tag.Get(envNameTag)
-	if key == "-" {
+	tag, ok := parseEnvTag(field, envNameTag)
+	if !ok {
 		return nil
 	}
-	if key == "" {
-		key = common.ToUpperSnake(field.typ.Name)
+
+	value := formatValue(field.value, tag.sep)
+
+	if isEmptyValue(field.value) {
+		switch {
+		case tag.hasDefault:
+			value = tag.defaultValue
+		case tag.omitEmpty, field.value.Kind() == reflect.Pointer, field.value.Kind() == reflect.Interface:
+			return nil
+		}
 	}
 
 	section := field.typ.Tag.Get(sectionTag)
 
 	return &updater.Update{
-		Key:     key,
-		Value:   fmt.Sprint(field.value.Interface()),
+		Key:     tag.name,
+		Value:   value,
 		Section: section,
 	}
 }
 
+const (
+	// Tag with a separator for slice and map values, "," by default.
+	sepTag     = "sep"
+	defaultSep = ","
+	// Tag with a value used when the field is empty.
+	defaultTag = "default"
+	// Separator between a key and a value of a map entry.
+	mapKeyValueSep = ":"
+)
+
+// envTag holds the settings of a struct field taken from its tags.
+type envTag struct {
+	name         string
+	omitEmpty    bool
+	sep          string
+	defaultValue string
+	hasDefault   bool
+}
+
+// parseEnvTag reads the env name tag (`env:"NAME,omitempty"`), "sep" and "default" tags of a field.
+// If the name is empty, the field name in UPPER_SNAKE_CASE is used.
+// Returns false if the field is tagged with "-" and must be skipped.
+func parseEnvTag(field reflectField, envNameTag string) (envTag, bool) {
+	name, opts, _ := strings.Cut(field.typ.Tag.Get(envNameTag), ",")
+	if name == "-" {
+		return envTag{}, false
+	}
+
+	if name == "" {
+		name = common.ToUpperSnake(field.typ.Name)
+	}
+
+	tag := envTag{
+		name: name,
+		sep:  defaultString(field.typ.Tag.Get(sepTag), defaultSep),
+	}
+
+	for opt := range strings.SplitSeq(opts, ",") {
+		if strings.TrimSpace(opt) == "omitempty" {
+			tag.omitEmpty = true
+		}
+	}
+
+	tag.defaultValue, tag.hasDefault = field.typ.Tag.Lookup(defaultTag)
+
+	return tag, true
+}
+
 // defaultString returns the value if non-empty, otherwise returns the default
 func defaultString(value, defaultVal string) string {
 	if value == "" {
@@ -0,0 +1,82 @@
+package envfile_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/4nd3r5on/go-envfile"
+	"github.com/4nd3r5on/go-envfile/updater"
+)
+
+func TestUpdatesFromStruct(t *testing.T) {
+	type config struct {
+		Host      string             `env:"HOST" section:"server"`
+		Port      int                `env:"PORT,omitempty" default:"8080"`
+		Tags      []string           `env:"TAGS" sep:";"`
+		Limits    map[string]int     `env:"LIMITS"`
+		Timeout   time.Duration      `env:"TIMEOUT"`
+		StartedAt time.Time          `env:"STARTED_AT"`
+		Name      *string            `env:"NAME"`
+		Nickname  *string            `env:"NICKNAME,omitempty"`
+		Extra     map[string]string  `env:",omitempty"`
+		Point     struct{ X, Y int } `env:"POINT"`
+		Secret    string             `env:"-"`
+	}
+
+	cfg := config{
+		Host:      "localhost",
+		Tags:      []string{"a", "b"},
+		Limits:    map[string]int{"write": 5, "read": 10},
+		Timeout:   90 * time.Second,
+		StartedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Point:     struct{ X, Y int }{X: 1, Y: 2},
+		Secret:    "hidden",
+	}
+
+	got := envfile.UpdatesFromStruct(&cfg, "", "")
+
+	want := []updater.Update{
+		{Key: "HOST", Value: "localhost", Section: "server"},
+		{Key: "PORT", Value: "8080"},
+		{Key: "TAGS", Value: "a;b"},
+		{Key: "LIMITS", Value: "read:10,write:5"},
+		{Key: "TIMEOUT", Value: "1m30s"},
+		{Key: "STARTED_AT", Value: "2024-01-02T03:04:05Z"},
+		{Key: "POINT", Value: "{1 2}"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpdatesFromStruct() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpdatesFromStructRoundTrip(t *testing.T) {
+	type config struct {
+		Tags    []string          `env:"TAGS"`
+		Paths   []string          `env:"PATHS" sep:";"`
+		Labels  map[string]string `env:"LABELS"`
+		Retries *int              `env:"RETRIES"`
+		Timeout *time.Duration    `env:"TIMEOUT"`
+	}
+
+	in := config{
+		Tags:   []string{"a,b", "c", ` padded `, `"quoted"`},
+		Paths:  []string{`C:\dir;x`, "/tmp"},
+		Labels: map[string]string{"host:port": "a,b", "k": "v:w"},
+	}
+
+	content, _, err := envfile.UpdateBytes(nil, envfile.UpdatesFromStruct(&in, "", ""), discardOptions())
+	if err != nil {
+		t.Fatalf("UpdateBytes() failed: %v", err)
+	}
+
+	var out config
+	if err := envfile.Unmarshal(parseLines(t, string(content)), &out); err != nil {
+		t.Fatalf("Unmarshal(%q) failed: %v", content, err)
+	}
+
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal(%q) = %+v, want %+v", content, out, in)
+	}
+}
@@ -0,0 +1,41 @@
+package interpolate
+
+import (
+	"os"
+
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+type Config struct {
+	// If set, references that are not defined in the file are looked up in the environment
+	UseEnv    bool
+	LookupEnv func(key string) (string, bool)
+	// Which references are expanded and how values are decoded, must match the dialect of the parser
+	Dialect parser.Dialect
+}
+
+var DefaultConfig = &Config{
+	UseEnv:    false,
+	LookupEnv: os.LookupEnv,
+	Dialect:   parser.DialectDefault,
+}
+
+type Option func(*Config)
+
+func SetUseEnv(v bool) Option {
+	return func(c *Config) { c.UseEnv = v }
+}
+
+// SetLookupEnv replaces os.LookupEnv as the environment source.
+// Implies SetUseEnv(true).
+func SetLookupEnv(fn func(key string) (string, bool)) Option {
+	return func(c *Config) {
+		c.LookupEnv = fn
+		c.UseEnv = true
+	}
+}
+
+// SetDialect sets the dialect references are expanded like.
+func SetDialect(d parser.Dialect) Option {
+	return func(c *Config) { c.Dialect = d }
+}
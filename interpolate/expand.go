@@ -0,0 +1,225 @@
+package interpolate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+var (
+	ErrRequired = errors.New("required variable is not set")
+	ErrSyntax   = errors.New("invalid variable reference")
+)
+
+// Lookup resolves a referenced variable.
+// Returns ok=false if the variable is not set.
+type Lookup func(key string) (value string, ok bool)
+
+// Expand expands references in a value as written in the file (escape sequences not decoded).
+// Supported forms: $VAR, ${VAR}, ${VAR:-default}, ${VAR-default},
+// ${VAR:?error}, ${VAR?error}, ${VAR:+alternate} and ${VAR+alternate}.
+// Single-quoted values are returned as is, double-quoted values get escape sequences decoded,
+// in unquoted values "\$" can be used for a literal dollar sign.
+func Expand(value string, quote byte, lookup Lookup) (string, error) {
+	return expand(value, quote, &parser.DialectDefault, lookup)
+}
+
+// expand expands references the way the dialect does.
+func expand(value string, quote byte, d *parser.Dialect, lookup Lookup) (string, error) {
+	if quote == '\'' || quote == '`' || d.Interpolation == parser.InterpolateNone {
+		return d.Decode(value, quote), nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(value))
+
+	for i := 0; i < len(value); {
+		switch value[i] {
+		case '\\':
+			if quote != 0 || d.UnquotedEscapes {
+				decoded, next := d.DecodeEscapeAt(value, i, quote)
+				sb.WriteString(decoded)
+				i = next
+
+				continue
+			}
+
+			if i+1 < len(value) && value[i+1] == '$' {
+				sb.WriteByte('$')
+				i += 2
+
+				continue
+			}
+
+			sb.WriteByte('\\')
+			i++
+		case '$':
+			if d.DollarEscape && i+1 < len(value) && value[i+1] == '$' {
+				sb.WriteByte('$')
+				i += 2
+
+				continue
+			}
+
+			expanded, next, err := expandReference(value, i, quote, d, lookup)
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(expanded)
+			i = next
+		default:
+			sb.WriteByte(value[i])
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// expandReference expands a reference starting at the '$' at pos.
+// Returns the expanded text and the index right after the reference.
+func expandReference(value string, pos int, quote byte, d *parser.Dialect, lookup Lookup) (string, int, error) {
+	if pos+1 >= len(value) {
+		return "$", pos + 1, nil
+	}
+
+	if value[pos+1] != '{' {
+		if d.Interpolation == parser.InterpolateBraces {
+			return "$", pos + 1, nil
+		}
+
+		nameEnd := scanName(value, pos+1)
+		if nameEnd == pos+1 {
+			// Not a reference, keep the dollar sign
+			return "$", pos + 1, nil
+		}
+
+		v, _ := lookup(value[pos+1 : nameEnd])
+
+		return v, nameEnd, nil
+	}
+
+	closeIdx := findClosingBrace(value, pos+2)
+	if closeIdx < 0 {
+		return "", 0, fmt.Errorf("%w: unterminated %q", ErrSyntax, value[pos:])
+	}
+
+	body := value[pos+2 : closeIdx]
+
+	nameEnd := scanName(body, 0)
+	if nameEnd == 0 {
+		return "", 0, fmt.Errorf("%w: %q", ErrSyntax, value[pos:closeIdx+1])
+	}
+
+	name := body[:nameEnd]
+
+	v, isSet := lookup(name)
+
+	if nameEnd == len(body) {
+		return v, closeIdx + 1, nil
+	}
+
+	op := body[nameEnd:]
+	if d.Interpolation == parser.InterpolateBraces && !strings.HasPrefix(op, ":-") {
+		// Not supported by the dialect, kept as is
+		return value[pos : closeIdx+1], closeIdx + 1, nil
+	}
+
+	checkEmpty := op[0] == ':'
+
+	if checkEmpty {
+		op = op[1:]
+	}
+
+	if op == "" {
+		return "", 0, fmt.Errorf("%w: %q", ErrSyntax, value[pos:closeIdx+1])
+	}
+
+	word := op[1:]
+	isSetForOp := isSet && (!checkEmpty || v != "")
+
+	switch op[0] {
+	case '-':
+		if isSetForOp {
+			return v, closeIdx + 1, nil
+		}
+
+		v, err := expand(word, quote, d, lookup)
+
+		return v, closeIdx + 1, err
+	case '+':
+		if !isSetForOp {
+			return "", closeIdx + 1, nil
+		}
+
+		v, err := expand(word, quote, d, lookup)
+
+		return v, closeIdx + 1, err
+	case '?':
+		if isSetForOp {
+			return v, closeIdx + 1, nil
+		}
+
+		msg, err := expand(word, quote, d, lookup)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if msg == "" {
+			return "", 0, fmt.Errorf("%w: %s", ErrRequired, name)
+		}
+
+		return "", 0, fmt.Errorf("%w: %s: %s", ErrRequired, name, msg)
+	default:
+		return "", 0, fmt.Errorf("%w: %q", ErrSyntax, value[pos:closeIdx+1])
+	}
+}
+
+// scanName returns the index right after a variable name starting at pos.
+// Returns pos if there is no valid name at pos.
+func scanName(s string, pos int) int {
+	i := pos
+	for i < len(s) {
+		c := s[i]
+
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+
+		if !isLetter && (!isDigit || i == pos) {
+			break
+		}
+
+		i++
+	}
+
+	return i
+}
+
+// findClosingBrace finds the '}' closing a "${" whose body starts at pos,
+// skipping nested references and escaped characters.
+// Returns -1 if the reference is not terminated.
+func findClosingBrace(s string, pos int) int {
+	depth := 1
+
+	for i := pos; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				depth++
+				i++
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,280 @@
+package interpolate_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/interpolate"
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+func TestExpandEntries(t *testing.T) {
+	env := map[string]string{"HOME": "/home/user", "EMPTY": ""}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+
+		return v, ok
+	}
+
+	tests := []struct {
+		name        string
+		entries     []interpolate.Entry
+		options     []interpolate.Option
+		want        map[string]string
+		wantErr     error
+		wantCycle   []string
+		wantForward *interpolate.ForwardReferenceError
+	}{
+		{
+			name: "braced and plain references",
+			entries: []interpolate.Entry{
+				{Key: "DB_USER", Value: "admin"},
+				{Key: "DB_HOST", Value: "localhost"},
+				{Key: "DATABASE_URL", Value: "postgres://${DB_USER}@$DB_HOST/db", Quote: '"'},
+			},
+			want: map[string]string{
+				"DB_USER":      "admin",
+				"DB_HOST":      "localhost",
+				"DATABASE_URL": "postgres://admin@localhost/db",
+			},
+		},
+		{
+			name: "single quoted value is not expanded",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "B", Value: "${A}", Quote: '\''},
+			},
+			want: map[string]string{"A": "x", "B": "${A}"},
+		},
+		{
+			name: "escaped dollar",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "B", Value: `\${A}`, Quote: '"'},
+				{Key: "C", Value: `\$A`},
+			},
+			want: map[string]string{"A": "x", "B": "${A}", "C": "$A"},
+		},
+		{
+			name: "default operators",
+			entries: []interpolate.Entry{
+				{Key: "EMPTY", Value: ""},
+				{Key: "A", Value: "${UNSET:-def}"},
+				{Key: "B", Value: "${EMPTY:-def}"},
+				{Key: "C", Value: "${EMPTY-def}"},
+				{Key: "D", Value: "${UNSET-${A}}"},
+			},
+			want: map[string]string{"EMPTY": "", "A": "def", "B": "def", "C": "", "D": "def"},
+		},
+		{
+			name: "alternate operators",
+			entries: []interpolate.Entry{
+				{Key: "EMPTY", Value: ""},
+				{Key: "SET", Value: "v"},
+				{Key: "A", Value: "${SET:+alt}"},
+				{Key: "B", Value: "${EMPTY:+alt}"},
+				{Key: "C", Value: "${EMPTY+alt}"},
+				{Key: "D", Value: "${UNSET+alt}"},
+			},
+			want: map[string]string{"EMPTY": "", "SET": "v", "A": "alt", "B": "", "C": "alt", "D": ""},
+		},
+		{
+			name: "error operator",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "${UNSET:?must be set}"},
+			},
+			wantErr: interpolate.ErrRequired,
+		},
+		{
+			name: "unterminated reference",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "${UNSET"},
+			},
+			wantErr: interpolate.ErrSyntax,
+		},
+		{
+			name: "forward reference",
+			entries: []interpolate.Entry{
+				{Key: "URL", Value: "${HOST:-localhost}"},
+				{Key: "HOST", Value: "x"},
+			},
+			options:     []interpolate.Option{interpolate.SetLookupEnv(lookupEnv)},
+			wantForward: &interpolate.ForwardReferenceError{Key: "URL", Ref: "HOST"},
+		},
+		{
+			name: "self reference uses environment",
+			entries: []interpolate.Entry{
+				{Key: "HOME", Value: "${HOME}/app"},
+			},
+			options: []interpolate.Option{interpolate.SetLookupEnv(lookupEnv)},
+			want:    map[string]string{"HOME": "/home/user/app"},
+		},
+		{
+			name: "redefinition uses earlier value",
+			entries: []interpolate.Entry{
+				{Key: "PATH", Value: "/bin"},
+				{Key: "PATH", Value: "${PATH}:/usr/bin"},
+			},
+			want: map[string]string{"PATH": "/bin:/usr/bin"},
+		},
+		{
+			name: "environment fallback",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "$HOME/app"},
+				{Key: "B", Value: "${EMPTY:-x}"},
+			},
+			options: []interpolate.Option{interpolate.SetLookupEnv(lookupEnv)},
+			want:    map[string]string{"A": "/home/user/app", "B": "x"},
+		},
+		{
+			name: "environment disabled",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "$HOME/app"},
+			},
+			want: map[string]string{"A": "/app"},
+		},
+		{
+			name: "cycle",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "${B}"},
+				{Key: "B", Value: "${C}"},
+				{Key: "C", Value: "${A}"},
+			},
+			wantCycle: []string{"A", "B", "C", "A"},
+		},
+		{
+			name: "mutual references",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "B", Value: "${C}"},
+				{Key: "C", Value: "${B}b"},
+			},
+			wantCycle: []string{"B", "C", "B"},
+		},
+		{
+			name: "compose dollar escape",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "B", Value: "$$A ${A}", Quote: '"'},
+			},
+			options: []interpolate.Option{interpolate.SetDialect(parser.DialectCompose)},
+			want:    map[string]string{"A": "x", "B": "$A x"},
+		},
+		{
+			name: "node doesn't expand",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "B", Value: `${A}\n`, Quote: '"'},
+			},
+			options: []interpolate.Option{interpolate.SetDialect(parser.DialectNode)},
+			want:    map[string]string{"A": "x", "B": "${A}\n"},
+		},
+		{
+			name: "python expands only braces",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "C", Value: `${A}\'`, Quote: '\''},
+				{Key: "B", Value: "$A ${A} ${C:-c} ${C-c}"},
+			},
+			options: []interpolate.Option{interpolate.SetDialect(parser.DialectPython)},
+			want:    map[string]string{"A": "x", "B": "$A x ${A}' ${C-c}", "C": "${A}'"},
+		},
+		{
+			name: "shell unquoted escapes",
+			entries: []interpolate.Entry{
+				{Key: "A", Value: "x"},
+				{Key: "B", Value: `\$A\ $A`},
+				{Key: "C", Value: `\n$A`, Quote: '"'},
+			},
+			options: []interpolate.Option{interpolate.SetDialect(parser.DialectShell)},
+			want:    map[string]string{"A": "x", "B": "$A x", "C": `\nx`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpolate.ExpandEntries(tt.entries, tt.options...)
+
+			if tt.wantCycle != nil {
+				var cycleErr *interpolate.CycleError
+				if !errors.As(err, &cycleErr) {
+					t.Fatalf("ExpandEntries() error = %v, want CycleError", err)
+				}
+
+				if !isRotation(cycleErr.Keys, tt.wantCycle) {
+					t.Errorf("CycleError.Keys = %v, want rotation of %v", cycleErr.Keys, tt.wantCycle)
+				}
+
+				return
+			}
+
+			if tt.wantForward != nil {
+				var forwardErr *interpolate.ForwardReferenceError
+				if !errors.As(err, &forwardErr) || *forwardErr != *tt.wantForward {
+					t.Fatalf("ExpandEntries() error = %v, want %v", err, tt.wantForward)
+				}
+
+				return
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ExpandEntries() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ExpandEntries() failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandEntries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandEntriesReportsFirstError(t *testing.T) {
+	entries := []interpolate.Entry{
+		{Key: "A", Value: "x"},
+		{Key: "B", Value: "${UNSET:?b}"},
+		{Key: "C", Value: "${UNSET"},
+		{Key: "D", Value: "${UNSET:?d}"},
+	}
+
+	for range 10 {
+		_, err := interpolate.ExpandEntries(entries)
+		if !errors.Is(err, interpolate.ErrRequired) || err.Error() != "failed to expand B: required variable is not set: UNSET: b" {
+			t.Fatalf("ExpandEntries() error = %v, want the error of B", err)
+		}
+	}
+}
+
+// isRotation reports whether cycle a (with the first key repeated at the end)
+// is the same cycle as b starting from a different key.
+func isRotation(a, b []string) bool {
+	if len(a) != len(b) || len(a) == 0 {
+		return false
+	}
+
+	n := len(a) - 1
+	for shift := range n {
+		match := true
+
+		for i := range n {
+			if a[i] != b[(i+shift)%n] {
+				match = false
+
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,202 @@
+package interpolate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Entry is a variable definition in file order.
+type Entry struct {
+	Key   string
+	Value string // Value as written in the file (escape sequences not decoded)
+	Quote byte   // Quote the value was written with, 0 if unquoted
+}
+
+// CycleError is returned when variables reference each other in a loop.
+type CycleError struct {
+	Keys []string // Keys forming the cycle, the first key is repeated at the end
+}
+
+func (e *CycleError) Error() string {
+	return "reference cycle: " + strings.Join(e.Keys, " -> ")
+}
+
+// ForwardReferenceError is returned when a value references a key the file defines only later.
+type ForwardReferenceError struct {
+	Key string // Key whose value has the reference
+	Ref string // Referenced key
+}
+
+func (e *ForwardReferenceError) Error() string {
+	return fmt.Sprintf("%s references %s, which is defined later in the file", e.Key, e.Ref)
+}
+
+type resolveState uint8
+
+const (
+	stateUnresolved resolveState = iota
+	stateResolving
+	stateResolved
+)
+
+type resolver struct {
+	cfg       Config
+	entries   []Entry
+	positions map[string][]int // key : indexes of its definitions
+
+	values []string
+	states []resolveState
+	stack  []int // definitions being resolved
+}
+
+// ExpandEntries expands references in every entry in file order and returns the final value of every key.
+// When a key is defined several times, the last definition wins.
+//
+// A reference is resolved against the closest earlier definition of the referenced key.
+// Keys not defined in the file are looked up in the environment (if enabled), otherwise they are unset.
+// A reference to a key the file defines only later results in a ForwardReferenceError,
+// or in a CycleError naming the keys if the later definition references back.
+// If several entries fail to expand, the first one in file order is reported.
+func ExpandEntries(entries []Entry, options ...Option) (map[string]string, error) {
+	cfg := *DefaultConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	r := &resolver{
+		cfg:       cfg,
+		entries:   entries,
+		positions: make(map[string][]int),
+		values:    make([]string, len(entries)),
+		states:    make([]resolveState, len(entries)),
+	}
+
+	for i, entry := range entries {
+		r.positions[entry.Key] = append(r.positions[entry.Key], i)
+	}
+
+	result := make(map[string]string, len(r.positions))
+
+	for i, entry := range entries {
+		v, err := r.resolve(i)
+		if err != nil {
+			return nil, err
+		}
+
+		result[entry.Key] = v
+	}
+
+	return result, nil
+}
+
+func (r *resolver) resolve(idx int) (string, error) {
+	switch r.states[idx] {
+	case stateResolved:
+		return r.values[idx], nil
+	case stateResolving:
+		return "", r.cycleError(idx)
+	}
+
+	entry := r.entries[idx]
+
+	r.states[idx] = stateResolving
+	r.stack = append(r.stack, idx)
+
+	var lookupErr error
+
+	v, err := expand(entry.Value, entry.Quote, &r.cfg.Dialect, func(key string) (string, bool) {
+		if lookupErr != nil {
+			return "", false
+		}
+
+		v, ok, err := r.lookup(idx, key)
+		lookupErr = err
+
+		return v, ok
+	})
+
+	switch {
+	case lookupErr != nil:
+		return "", lookupErr
+	case err != nil:
+		return "", &keyError{key: entry.Key, err: err}
+	}
+
+	r.stack = r.stack[:len(r.stack)-1]
+	r.states[idx] = stateResolved
+	r.values[idx] = v
+
+	return v, nil
+}
+
+// lookup resolves key as referenced from the definition at idx.
+func (r *resolver) lookup(from int, key string) (string, bool, error) {
+	positions := r.positions[key]
+
+	earlier, later := -1, -1
+
+	for _, pos := range positions {
+		switch {
+		case pos < from:
+			earlier = pos // closest earlier definition
+		case pos > from && later < 0:
+			later = pos
+		}
+	}
+
+	if earlier >= 0 {
+		v, err := r.resolve(earlier)
+
+		return v, err == nil, err
+	}
+
+	if later >= 0 {
+		// Resolve the later definition to find out whether it references back
+		var cycleErr *CycleError
+		if _, err := r.resolve(later); errors.As(err, &cycleErr) {
+			return "", false, err
+		}
+
+		return "", false, &ForwardReferenceError{Key: r.entries[from].Key, Ref: key}
+	}
+
+	if r.cfg.UseEnv && r.cfg.LookupEnv != nil {
+		v, ok := r.cfg.LookupEnv(key)
+
+		return v, ok, nil
+	}
+
+	return "", false, nil
+}
+
+func (r *resolver) cycleError(idx int) error {
+	start := 0
+
+	for i, pos := range r.stack {
+		if pos == idx {
+			start = i
+
+			break
+		}
+	}
+
+	keys := make([]string, 0, len(r.stack)-start+1)
+	for _, pos := range r.stack[start:] {
+		keys = append(keys, r.entries[pos].Key)
+	}
+
+	keys = append(keys, r.entries[idx].Key)
+
+	return &CycleError{Keys: keys}
+}
+
+// keyError attaches the key whose value failed to expand.
+type keyError struct {
+	key string
+	err error
+}
+
+func (e *keyError) Error() string { return fmt.Sprintf("failed to expand %s: %v", e.key, e.err) }
+
+func (e *keyError) Unwrap() error { return e.err }
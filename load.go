@@ -0,0 +1,75 @@
+package envfile
+
+import (
+	"os"
+	"slices"
+
+	"github.com/safeblock-dev/werr"
+)
+
+// DefaultEnvFile is loaded by Load and Overload when no paths are given.
+const DefaultEnvFile = ".env"
+
+// LoadResult reports which keys were put into the process environment.
+type LoadResult struct {
+	Set     []string // Keys that were set
+	Skipped []string // Keys that were skipped because they were already present
+}
+
+// Load reads env files and sets variables that are not present in the process environment yet.
+// Files are processed in order, so the first file defining a key wins.
+// Loads ".env" if no paths are given.
+func Load(paths ...string) (LoadResult, error) {
+	return load(false, paths)
+}
+
+// Overload reads env files and sets all their variables, overwriting the process environment.
+// Files are processed in order, so the last file defining a key wins.
+// Loads ".env" if no paths are given.
+func Overload(paths ...string) (LoadResult, error) {
+	return load(true, paths)
+}
+
+func load(overwrite bool, paths []string) (LoadResult, error) {
+	if len(paths) == 0 {
+		paths = []string{DefaultEnvFile}
+	}
+
+	var result LoadResult
+
+	for _, path := range paths {
+		lines, err := ParseFile(path, NewParser())
+		if err != nil {
+			return result, werr.Wrapf(err, "failed to parse %q", path)
+		}
+
+		vars := LinesToVariableMap(lines)
+
+		keys := make([]string, 0, len(vars))
+		for key := range vars {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			if _, exists := os.LookupEnv(key); exists && !overwrite {
+				if !slices.Contains(result.Set, key) && !slices.Contains(result.Skipped, key) {
+					result.Skipped = append(result.Skipped, key)
+				}
+
+				continue
+			}
+
+			if err := os.Setenv(key, vars[key]); err != nil {
+				return result, werr.Wrapf(err, "failed to set %q from %q", key, path)
+			}
+
+			if !slices.Contains(result.Set, key) {
+				result.Set = append(result.Set, key)
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package envfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile"
+)
+
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write env file: %v", err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("GO_ENVFILE_PRESENT", "from-env")
+	t.Setenv("GO_ENVFILE_NEW", "")
+	os.Unsetenv("GO_ENVFILE_NEW")
+
+	path := writeEnvFile(t, "GO_ENVFILE_PRESENT=from-file\nGO_ENVFILE_NEW=\"new value\"\n")
+
+	got, err := envfile.Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := envfile.LoadResult{
+		Set:     []string{"GO_ENVFILE_NEW"},
+		Skipped: []string{"GO_ENVFILE_PRESENT"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+
+	if v := os.Getenv("GO_ENVFILE_PRESENT"); v != "from-env" {
+		t.Errorf("GO_ENVFILE_PRESENT = %q, want %q", v, "from-env")
+	}
+
+	if v := os.Getenv("GO_ENVFILE_NEW"); v != "new value" {
+		t.Errorf("GO_ENVFILE_NEW = %q, want %q", v, "new value")
+	}
+}
+
+func TestOverload(t *testing.T) {
+	t.Setenv("GO_ENVFILE_PRESENT", "from-env")
+
+	first := writeEnvFile(t, "GO_ENVFILE_PRESENT=first\n")
+	second := writeEnvFile(t, "GO_ENVFILE_PRESENT=second\n")
+
+	got, err := envfile.Overload(first, second)
+	if err != nil {
+		t.Fatalf("Overload() failed: %v", err)
+	}
+
+	want := envfile.LoadResult{Set: []string{"GO_ENVFILE_PRESENT"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Overload() = %+v, want %+v", got, want)
+	}
+
+	if v := os.Getenv("GO_ENVFILE_PRESENT"); v != "second" {
+		t.Errorf("GO_ENVFILE_PRESENT = %q, want %q", v, "second")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := envfile.Load(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Fatal("Load() succeeded unexpectedly")
+	}
+}
@@ -5,6 +5,13 @@ import "log/slog"
 type Config struct {
 	Logger         *slog.Logger
 	IgnoreSections bool
+	// Accept lines with a key only ("KEY"), meaning the value is inherited from the environment
+	AllowBareKeys bool
+	// Accept only "NAME=" and "export NAME=" with POSIX names (see ExtractKeyStrict),
+	// Dialect.KeyChars are allowed in names too
+	StrictKeys bool
+	// How values are read, see Dialect
+	Dialect Dialect
 }
 
 type Option func(*Config)
@@ -20,3 +27,22 @@ func SetIgnoreSections(ignore bool) Option {
 		c.IgnoreSections = ignore
 	}
 }
+
+func SetAllowBareKeys(allow bool) Option {
+	return func(c *Config) {
+		c.AllowBareKeys = allow
+	}
+}
+
+func SetStrictKeys(strict bool) Option {
+	return func(c *Config) {
+		c.StrictKeys = strict
+	}
+}
+
+// SetDialect sets how values are read, e.g. SetDialect(DialectCompose).
+func SetDialect(d Dialect) Option {
+	return func(c *Config) {
+		c.Dialect = d
+	}
+}
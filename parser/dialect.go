@@ -0,0 +1,323 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Interpolation defines which variable references a dialect expands.
+type Interpolation uint8
+
+const (
+	// InterpolateNone keeps references as is.
+	InterpolateNone Interpolation = iota
+	// InterpolateBraces expands only ${VAR} and ${VAR:-default}, other forms are kept as is.
+	InterpolateBraces
+	// InterpolateAll expands $VAR and every ${VAR...} form.
+	InterpolateAll
+)
+
+// Dialect describes how a consumer of env files reads values.
+// Presets: DialectDefault, DialectCompose, DialectNode, DialectPython and DialectShell.
+type Dialect struct {
+	Name string
+	// Characters decoded after a backslash in double-quoted values:
+	// 'n', 't', 'r', 'a', 'b', 'f' and 'v' are control characters, 'u' is \uXXXX,
+	// any other character stands for itself. Backslashes before other characters are kept.
+	DoubleQuoteEscapes string
+	// Same as DoubleQuoteEscapes for single-quoted values
+	SingleQuoteEscapes string
+	// A backslash escapes any character in unquoted values
+	UnquotedEscapes bool
+	// A backslash before a quote never ends a quoted value, even if the sequence isn't decoded
+	SkipEscapedQuotes bool
+	// Backticks quote values, their content is literal
+	Backticks bool
+	// Any '#' starts an inline comment in unquoted values, not only one after whitespace
+	HashComments bool
+	// Unquoted values can't contain unescaped whitespace, quotes and shell special characters
+	StrictUnquoted bool
+	// References expanded in unquoted and double-quoted values
+	Interpolation Interpolation
+	// "$$" is a literal '$'
+	DollarEscape bool
+	// Characters allowed in keys besides letters, digits and '_' (Config.StrictKeys)
+	KeyChars string
+}
+
+var (
+	// DialectDefault is how this library reads env files.
+	DialectDefault = Dialect{
+		Name:               "default",
+		DoubleQuoteEscapes: `ntr\"$u`,
+		SkipEscapedQuotes:  true,
+		Interpolation:      InterpolateAll,
+	}
+	// DialectCompose is how docker compose reads env files.
+	DialectCompose = Dialect{
+		Name:               "compose",
+		DoubleQuoteEscapes: `abfnrtv\"'$`,
+		SkipEscapedQuotes:  true,
+		Interpolation:      InterpolateAll,
+		DollarEscape:       true,
+	}
+	// DialectNode is how the dotenv package for Node.js reads env files.
+	DialectNode = Dialect{
+		Name:               "node",
+		DoubleQuoteEscapes: "nr",
+		SkipEscapedQuotes:  true,
+		Backticks:          true,
+		HashComments:       true,
+		Interpolation:      InterpolateNone,
+		KeyChars:           ".-",
+	}
+	// DialectPython is how python-dotenv reads env files.
+	DialectPython = Dialect{
+		Name:               "python",
+		DoubleQuoteEscapes: `abfnrtv\"'`,
+		SingleQuoteEscapes: `\'`,
+		Interpolation:      InterpolateBraces,
+	}
+	// DialectShell is how a POSIX shell reads an env file it sources.
+	DialectShell = Dialect{
+		Name:               "shell",
+		DoubleQuoteEscapes: "$`\"\\",
+		UnquotedEscapes:    true,
+		StrictUnquoted:     true,
+		Interpolation:      InterpolateAll,
+	}
+)
+
+// IsQuote reports whether c starts a quoted value.
+func (d *Dialect) IsQuote(c byte) bool {
+	return c == '"' || c == '\'' || (c == '`' && d.Backticks)
+}
+
+// Escapes returns the characters decoded after a backslash in values quoted with quote.
+func (d *Dialect) Escapes(quote byte) string {
+	switch quote {
+	case '"':
+		return d.DoubleQuoteEscapes
+	case '\'':
+		return d.SingleQuoteEscapes
+	default:
+		return ""
+	}
+}
+
+// Decode decodes escape sequences of value content written with quote (0 if unquoted).
+func (d *Dialect) Decode(content string, quote byte) string {
+	if strings.IndexByte(content, '\\') < 0 {
+		return content
+	}
+
+	if (quote == 0 && !d.UnquotedEscapes) || (quote != 0 && d.Escapes(quote) == "") {
+		return content
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(content))
+
+	for i := 0; i < len(content); {
+		if content[i] != '\\' {
+			sb.WriteByte(content[i])
+			i++
+
+			continue
+		}
+
+		decoded, next := d.DecodeEscapeAt(content, i, quote)
+		sb.WriteString(decoded)
+		i = next
+	}
+
+	return sb.String()
+}
+
+// Encode returns value written with quote (0 for unquoted), including the quotes,
+// so the dialect reads it back unchanged, interpolation included:
+// '$' is written as "$$" or "\$" if the dialect supports it.
+// Returns false if the value can't be written with that quote.
+func (d *Dialect) Encode(value string, quote byte) (string, bool) {
+	switch quote {
+	case 0:
+		if !d.canBeUnquoted(value) {
+			return "", false
+		}
+
+		if d.DollarEscape && d.interpolates(0) {
+			return strings.ReplaceAll(value, "$", "$$"), true
+		}
+
+		return value, true
+	case '"', '\'':
+		return d.encodeQuoted(value, quote)
+	case '`':
+		if !d.Backticks || strings.IndexByte(value, '`') >= 0 || strings.HasSuffix(value, `\`) {
+			return "", false
+		}
+
+		return "`" + value + "`", true
+	default:
+		return "", false
+	}
+}
+
+// encodeQuoted escapes value for single or double quotes.
+func (d *Dialect) encodeQuoted(value string, quote byte) (string, bool) {
+	escapes := d.Escapes(quote)
+	canEscape := func(c byte) bool { return strings.IndexByte(escapes, c) >= 0 }
+	interpolates := d.interpolates(quote)
+
+	var sb strings.Builder
+	sb.Grow(len(value) + 2)
+	sb.WriteByte(quote)
+
+	for i := range len(value) {
+		c := value[i]
+
+		switch {
+		case (c == quote || c == '\\' || c == '`') && canEscape(c):
+			sb.WriteByte('\\')
+		case c == quote:
+			return "", false
+		case c == '\\':
+			// A literal backslash must not form an escape sequence or escape the closing quote
+			isLast := i+1 == len(value)
+			if (isLast && (d.SkipEscapedQuotes || canEscape(quote))) || (!isLast && canEscape(value[i+1])) {
+				return "", false
+			}
+		case c == '$' && interpolates:
+			switch {
+			case d.DollarEscape:
+				sb.WriteByte('$')
+			case canEscape('$'):
+				sb.WriteByte('\\')
+			case d.expandsAt(value, i):
+				return "", false
+			}
+		}
+
+		sb.WriteByte(c)
+	}
+
+	sb.WriteByte(quote)
+
+	return sb.String(), true
+}
+
+// interpolates reports whether the dialect expands references in values quoted with quote.
+func (d *Dialect) interpolates(quote byte) bool {
+	return d.Interpolation != InterpolateNone && (quote == 0 || quote == '"')
+}
+
+// expandsAt reports whether the dialect reads the '$' at pos as something else than a literal '$':
+// the start of a reference or, with DollarEscape, of "$$".
+func (d *Dialect) expandsAt(value string, pos int) bool {
+	if pos+1 >= len(value) {
+		return false
+	}
+
+	switch next := value[pos+1]; {
+	case next == '{':
+		return true
+	case next == '$':
+		return d.DollarEscape
+	case d.Interpolation == InterpolateAll:
+		return next == '_' || (next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z')
+	default:
+		return false
+	}
+}
+
+// canBeUnquoted reports whether the dialect reads value back unchanged without quotes.
+func (d *Dialect) canBeUnquoted(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	if strings.TrimSpace(value) != value || strings.ContainsAny(value, "\r\n") || d.IsQuote(value[0]) {
+		return false
+	}
+
+	if d.StrictUnquoted {
+		return !strings.ContainsFunc(value, func(c rune) bool {
+			isWordChar := c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c))
+
+			return !isWordChar && !strings.ContainsRune("_@%+=:,./-{}", c)
+		})
+	}
+
+	if d.UnquotedEscapes && strings.IndexByte(value, '\\') >= 0 {
+		return false
+	}
+
+	if d.interpolates(0) {
+		// "\$" is read as '$', references can be escaped only with DollarEscape
+		for i := range len(value) {
+			if (value[i] == '\\' && i+1 < len(value) && value[i+1] == '$') ||
+				(value[i] == '$' && !d.DollarEscape && d.expandsAt(value, i)) {
+				return false
+			}
+		}
+	}
+
+	return d.findInlineComment(value, 0) < 0
+}
+
+// findInlineComment returns the index of '#' starting an inline comment
+// at or after pos, or -1.
+// Unless HashComments is set, '#' must be preceded by whitespace,
+// so '#' inside a word, like in "http://host/#anchor", doesn't start a comment.
+func (d *Dialect) findInlineComment(line string, pos int) int {
+	for i := pos; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && d.UnquotedEscapes:
+			i++
+		case line[i] != '#':
+		case d.HashComments || i == 0 || unicode.IsSpace(rune(line[i-1])):
+			return i
+		}
+	}
+
+	return -1
+}
+
+// findUnquotedSpecial returns the index of the first unescaped whitespace,
+// quote or shell special character in an unquoted value, or -1.
+func (d *Dialect) findUnquotedSpecial(value string) int {
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && d.UnquotedEscapes:
+			i++
+		case unicode.IsSpace(rune(c)) || strings.IndexByte("\"'`;&|<>()", c) >= 0:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// findClosingQuote returns the index of the quote closing a value, searching from start, or -1.
+func (d *Dialect) findClosingQuote(line string, start int, quote byte) int {
+	skipEscaped := d.SkipEscapedQuotes || strings.IndexByte(d.Escapes(quote), quote) >= 0
+
+	bsCount := 0 // count of consecutive backslashes
+
+	for i := start; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			bsCount++
+
+			continue
+		}
+
+		if c == quote && (!skipEscaped || bsCount%2 == 0) {
+			return i
+		}
+
+		bsCount = 0
+	}
+
+	return -1
+}
@@ -0,0 +1,143 @@
+package parser_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/interpolate"
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+func TestDialectParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect parser.Dialect
+		line    string
+		want    string
+	}{
+		{name: "default double quoted", dialect: parser.DialectDefault, line: `A="a\nb\tc\$"`, want: "a\nb\tc$"},
+		{name: "default single quoted", dialect: parser.DialectDefault, line: `A='a\nb'`, want: `a\nb`},
+		{name: "default backticks", dialect: parser.DialectDefault, line: "A=`a b`", want: "`a b`"},
+		{name: "compose double quoted", dialect: parser.DialectCompose, line: `A="it\'s\a"`, want: "it's\a"},
+		{name: "node double quoted", dialect: parser.DialectNode, line: `A="a\nb\tc"`, want: "a\nb\\tc"},
+		{name: "node escaped quote", dialect: parser.DialectNode, line: `A="a\"b"`, want: `a\"b`},
+		{name: "node backticks", dialect: parser.DialectNode, line: "A=`it's \"x\"` # comment", want: `it's "x"`},
+		{name: "node hash", dialect: parser.DialectNode, line: "A=x#y", want: "x"},
+		{name: "node only hash", dialect: parser.DialectNode, line: "A=#y", want: ""},
+		{name: "python single quoted", dialect: parser.DialectPython, line: `A='it\'s \\ \n'`, want: `it's \ \n`},
+		{name: "python double quoted", dialect: parser.DialectPython, line: `A="\a\b\f\v\$"`, want: "\a\b\f\v\\$"},
+		{name: "shell single quoted", dialect: parser.DialectShell, line: `A='a\' # comment`, want: `a\`},
+		{name: "shell double quoted", dialect: parser.DialectShell, line: `A="\n\$\"\\"`, want: `\n$"\`},
+		{name: "shell unquoted", dialect: parser.DialectShell, line: `A=a\ b\#c\\ #d`, want: `a b#c\`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.New(parser.SetDialect(tt.dialect)).ParseLine(tt.line)
+			if err != nil {
+				t.Fatalf("ParseLine() failed: %v", err)
+			}
+
+			if got.Variable == nil || got.Variable.Value != tt.want {
+				t.Errorf("ParseLine() variable = %+v, want value %q", got.Variable, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectEncode(t *testing.T) {
+	dialects := []parser.Dialect{
+		parser.DialectDefault,
+		parser.DialectCompose,
+		parser.DialectNode,
+		parser.DialectPython,
+		parser.DialectShell,
+	}
+	values := []string{
+		"", "plain", "with space", " padded ", "#hash", "a #b", "a#b", "it's", `say "hi"`,
+		`back\slash`, `trailing\`, `\n`, `\"`, "$HOME", "`cmd`", "semi;colon", "tab\tinside",
+		"pa$word", "x$$y", "a$HOME", "${A}", "${A:-x}", "$", "a$", "a$ b", `a\$b`, "$1",
+	}
+	quotes := []byte{0, '"', '\'', '`'}
+
+	for _, dialect := range dialects {
+		for _, value := range values {
+			encodedOnce := false
+
+			for _, quote := range quotes {
+				encoded, ok := dialect.Encode(value, quote)
+				if !ok {
+					continue
+				}
+
+				encodedOnce = true
+
+				got, err := parser.New(parser.SetDialect(dialect)).ParseLine("A=" + encoded)
+				if err != nil {
+					t.Fatalf("%s: ParseLine(%q) failed: %v", dialect.Name, encoded, err)
+				}
+
+				// "$$" is left to interpolation
+				if !dialect.DollarEscape && got.Variable.Value != value {
+					t.Errorf("%s: %q encoded as %q, decoded as %q", dialect.Name, value, encoded, got.Variable.Value)
+				}
+
+				expanded, err := interpolate.ExpandEntries(
+					[]interpolate.Entry{{Key: "A", Value: got.Variable.RawValue, Quote: got.Variable.Quote}},
+					interpolate.SetDialect(dialect),
+				)
+				if err != nil {
+					t.Fatalf("%s: %q encoded as %q, expansion failed: %v", dialect.Name, value, encoded, err)
+				}
+
+				if expanded["A"] != value {
+					t.Errorf("%s: %q encoded as %q, expanded as %q", dialect.Name, value, encoded, expanded["A"])
+				}
+			}
+
+			if !encodedOnce {
+				t.Errorf("%s: %q can't be encoded", dialect.Name, value)
+			}
+		}
+	}
+}
+
+func TestDialectShellUnquoted(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    string
+		wantCol int
+	}{
+		{line: `A=hello`, want: "hello"},
+		{line: `A=hello # comment`, want: "hello"},
+		{line: `A=hello\ world`, want: "hello world"},
+		{line: `A=hello world`, wantCol: 8},
+		{line: `A=a;b`, wantCol: 4},
+		{line: `A=a"b c"`, wantCol: 4},
+		{line: "A=a`b`", wantCol: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parser.New(parser.SetDialect(parser.DialectShell)).ParseLine(tt.line)
+			if tt.wantCol == 0 {
+				if err != nil {
+					t.Fatalf("ParseLine() failed: %v", err)
+				}
+
+				if got.Variable.Value != tt.want {
+					t.Errorf("ParseLine() value = %q, want %q", got.Variable.Value, tt.want)
+				}
+
+				return
+			}
+
+			var parseErr *parser.ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(err, parser.ErrInvalidValue) {
+				t.Fatalf("ParseLine() error = %v, want ErrInvalidValue", err)
+			}
+
+			if parseErr.Column != tt.wantCol {
+				t.Errorf("ParseLine() error column = %d, want %d", parseErr.Column, tt.wantCol)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Kinds of parse errors, ParseError unwraps to one of them.
+var (
+	// ErrNoKey means there is no key before '='.
+	ErrNoKey = errors.New("no key")
+	// ErrMissingEquals means a line is neither a comment nor an assignment.
+	ErrMissingEquals = errors.New("missing '='")
+	// ErrInvalidKey means a key is rejected in strict mode (Config.StrictKeys).
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrInvalidValue means an unquoted value is rejected by the dialect (Dialect.StrictUnquoted).
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrUnterminatedQuote means the input ended inside a quoted value.
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+)
+
+// ParseError describes where and why parsing failed.
+// The message doesn't include Text, as it may contain secret values.
+type ParseError struct {
+	Path   string // Empty if unknown
+	Line   int    // Starts from 1, 0 if unknown
+	Column int    // Byte offset in the line, starts from 1, 0 if unknown
+	Text   string // The offending line
+	Kind   error  // ErrNoKey, ErrMissingEquals, ErrInvalidKey, ErrInvalidValue or ErrUnterminatedQuote
+	Detail string
+}
+
+func (e *ParseError) Error() string {
+	msg := "parse error"
+	if e.Kind != nil {
+		msg = e.Kind.Error()
+	}
+
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+
+	var pos string
+
+	switch {
+	case e.Path != "":
+		pos = e.Path
+		if e.Line > 0 {
+			pos += ":" + strconv.Itoa(e.Line)
+			if e.Column > 0 {
+				pos += ":" + strconv.Itoa(e.Column)
+			}
+		}
+	case e.Line > 0 && e.Column > 0:
+		pos = fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	case e.Line > 0:
+		pos = fmt.Sprintf("line %d", e.Line)
+	case e.Column > 0:
+		pos = fmt.Sprintf("column %d", e.Column)
+	default:
+		return msg
+	}
+
+	return pos + ": " + msg
+}
+
+func (e *ParseError) Unwrap() error { return e.Kind }
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodeEscapes decodes backslash escape sequences of a double-quoted value.
+// Supported sequences: \n, \t, \r, \\, \", \$ and \uXXXX.
+// Unknown or malformed sequences are kept as is.
+// Use Dialect.Decode for other dialects.
+func DecodeEscapes(s string) string {
+	return DialectDefault.Decode(s, '"')
+}
+
+// DecodeEscapeAt decodes a single escape sequence starting at the backslash at pos.
+// Returns the decoded text and the index right after the sequence.
+func DecodeEscapeAt(s string, pos int) (string, int) {
+	return decodeEscapeAt(s, pos, DialectDefault.DoubleQuoteEscapes)
+}
+
+// DecodeEscapeAt decodes a single escape sequence starting at the backslash at pos
+// the way the dialect does in values quoted with quote.
+func (d *Dialect) DecodeEscapeAt(s string, pos int, quote byte) (string, int) {
+	if quote == 0 && d.UnquotedEscapes {
+		if pos+1 >= len(s) {
+			return s[pos:], len(s)
+		}
+
+		return s[pos+1 : pos+2], pos + 2
+	}
+
+	return decodeEscapeAt(s, pos, d.Escapes(quote))
+}
+
+// decodeEscapeAt decodes an escape sequence at pos if escapes contains the escaped character.
+func decodeEscapeAt(s string, pos int, escapes string) (string, int) {
+	if pos+1 >= len(s) {
+		return s[pos:], len(s)
+	}
+
+	c := s[pos+1]
+	if strings.IndexByte(escapes, c) < 0 {
+		// Unknown sequence, keep the backslash and the next character
+		return s[pos : pos+2], pos + 2
+	}
+
+	switch c {
+	case 'n':
+		return "\n", pos + 2
+	case 't':
+		return "\t", pos + 2
+	case 'r':
+		return "\r", pos + 2
+	case 'a':
+		return "\a", pos + 2
+	case 'b':
+		return "\b", pos + 2
+	case 'f':
+		return "\f", pos + 2
+	case 'v':
+		return "\v", pos + 2
+	case 'u':
+		if pos+6 > len(s) {
+			break
+		}
+
+		code, err := strconv.ParseUint(s[pos+2:pos+6], 16, 32)
+		if err != nil {
+			break
+		}
+
+		buf := make([]byte, 0, utf8.UTFMax)
+
+		return string(utf8.AppendRune(buf, rune(code))), pos + 6
+	default:
+		return string(c), pos + 2
+	}
+
+	// Malformed \u sequence
+	return s[pos : pos+2], pos + 2
+}
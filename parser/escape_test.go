@@ -0,0 +1,70 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+func TestDecodeEscapes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "no escapes", in: "plain value", want: "plain value"},
+		{name: "newline", in: `line1\nline2`, want: "line1\nline2"},
+		{name: "tab", in: `a\tb`, want: "a\tb"},
+		{name: "carriage return", in: `a\rb`, want: "a\rb"},
+		{name: "backslash", in: `C:\\path`, want: `C:\path`},
+		{name: "double quote", in: `say \"hi\"`, want: `say "hi"`},
+		{name: "dollar", in: `\$HOME`, want: `$HOME`},
+		{name: "unicode", in: `\u00e9t\u00E9`, want: "été"},
+		{name: "short unicode kept", in: `\u12`, want: `\u12`},
+		{name: "invalid unicode kept", in: `\uzzzz`, want: `\uzzzz`},
+		{name: "unknown escape kept", in: `\q`, want: `\q`},
+		{name: "trailing backslash kept", in: `abc\`, want: `abc\`},
+		{name: "escaped backslash before n", in: `\\n`, want: `\n`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.DecodeEscapes(tt.in); got != tt.want {
+				t.Errorf("DecodeEscapes(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineDecodesValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantVal string
+		wantRaw string
+	}{
+		{name: "double quoted", line: `KEY="line1\nline2"`, wantVal: "line1\nline2", wantRaw: `line1\nline2`},
+		{name: "escaped quote", line: `KEY="a \"b\" c"`, wantVal: `a "b" c`, wantRaw: `a \"b\" c`},
+		{name: "single quoted is literal", line: `KEY='line1\nline2'`, wantVal: `line1\nline2`, wantRaw: `line1\nline2`},
+		{name: "unquoted is literal", line: `KEY=a\nb`, wantVal: `a\nb`, wantRaw: `a\nb`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.New().ParseLine(tt.line)
+			if err != nil {
+				t.Fatalf("ParseLine() failed: %v", err)
+			}
+
+			if got.Variable == nil {
+				t.Fatal("ParseLine() returned no variable")
+			}
+
+			if got.Variable.Value != tt.wantVal {
+				t.Errorf("ParseLine().Variable.Value = %q, want %q", got.Variable.Value, tt.wantVal)
+			}
+
+			if got.Variable.RawValue != tt.wantRaw {
+				t.Errorf("ParseLine().Variable.RawValue = %q, want %q", got.Variable.RawValue, tt.wantRaw)
+			}
+		})
+	}
+}
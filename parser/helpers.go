@@ -1,8 +1,9 @@
 package parser
 
 import (
-	"errors"
+	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/4nd3r5on/go-envfile/common"
 )
@@ -42,13 +43,13 @@ func DetectLineType(line string) common.LineType {
 // Returns the key string or error if invalid.
 func ExtractKey(line string, equalIdx int) (KeyData, error) {
 	if equalIdx == 0 {
-		return KeyData{}, errors.New("no key: equals sign at start of line")
+		return KeyData{}, &ParseError{Column: 1, Kind: ErrNoKey, Detail: "'=' at start of line"}
 	}
 
 	// Find last non-space before '='
 	keyEnd := common.SkipSpacesBack(line, equalIdx-1)
 	if keyEnd == -1 {
-		return KeyData{}, errors.New("no key: only spaces before equals sign")
+		return KeyData{}, &ParseError{Column: equalIdx + 1, Kind: ErrNoKey, Detail: "only spaces before '='"}
 	}
 
 	// Find start of a key
@@ -61,55 +62,116 @@ func ExtractKey(line string, equalIdx int) (KeyData, error) {
 	}, nil
 }
 
+// ExtractKeyStrict extracts the key from line given the position of '=' (len(line) for bare keys).
+// Only "NAME=" and "export NAME=" are accepted, optionally indented.
+// NAME must start with a letter or '_' and contain only letters, digits, '_'
+// and the characters in extraChars (e.g. ".-").
+// Returns *ParseError if the key is rejected.
+func ExtractKeyStrict(line string, equalIdx int, extraChars string) (KeyData, error) {
+	keyStart := common.SkipSpaces(line, 0)
+	if rest := line[keyStart:equalIdx]; strings.HasPrefix(rest, "export ") || strings.HasPrefix(rest, "export\t") {
+		keyStart = common.SkipSpaces(line, keyStart+len("export"))
+	}
+
+	keyEnd := keyStart
+	for keyEnd < equalIdx && isKeyChar(line[keyEnd], keyEnd == keyStart, extraChars) {
+		keyEnd++
+	}
+
+	switch {
+	case keyEnd == equalIdx && keyStart < equalIdx:
+		return KeyData{Key: line[keyStart:keyEnd], Start: keyStart, End: keyEnd - 1}, nil
+	case keyStart >= equalIdx:
+		return KeyData{}, &ParseError{Column: keyStart + 1, Kind: ErrNoKey}
+	case keyEnd == keyStart && line[keyStart] >= '0' && line[keyStart] <= '9':
+		return KeyData{}, &ParseError{Column: keyStart + 1, Kind: ErrInvalidKey, Detail: "starts with a digit"}
+	case common.IsEmptyStr(line[keyEnd:equalIdx]):
+		return KeyData{}, &ParseError{Column: keyEnd + 1, Kind: ErrInvalidKey, Detail: "whitespace before '='"}
+	case unicode.IsSpace(rune(line[keyEnd])):
+		return KeyData{}, &ParseError{
+			Column: keyStart + 1,
+			Kind:   ErrInvalidKey,
+			Detail: fmt.Sprintf("unknown prefix %q", line[keyStart:common.UntilSpaceBack(line, equalIdx-1)]),
+		}
+	default:
+		return KeyData{}, &ParseError{
+			Column: keyEnd + 1,
+			Kind:   ErrInvalidKey,
+			Detail: fmt.Sprintf("unexpected character %q", line[keyEnd]),
+		}
+	}
+}
+
+// isKeyChar reports whether c can be a part of a key in strict mode.
+func isKeyChar(c byte, isFirst bool, extraChars string) bool {
+	switch {
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return true
+	case isFirst:
+		return false
+	default:
+		return (c >= '0' && c <= '9') || strings.IndexByte(extraChars, c) >= 0
+	}
+}
+
 // ExtractValue extracts the value from line given the position of '='
 // Returns structured value data and any error.
+// Nothing, only spaces or only an inline comment after '=' is an empty unquoted value
+// starting right after '='.
+// The value is read as DialectDefault does.
 func ExtractValue(line string, equalIdx int) (ValueData, error) {
+	return extractValue(line, equalIdx, &DialectDefault)
+}
+
+func extractValue(line string, equalIdx int, d *Dialect) (ValueData, error) {
 	// Find value start
 	valStart := common.SkipSpaces(line, equalIdx+1)
-	if valStart >= len(line) {
-		return ValueData{}, errors.New("no value: nothing after equals sign")
+	if valStart >= len(line) || d.findInlineComment(line, equalIdx+1) == valStart {
+		return ValueData{
+			Start:        equalIdx + 1,
+			End:          equalIdx,
+			Type:         ValueUnquoted,
+			IsTerminated: true,
+		}, nil
 	}
 
 	// Check if quoted
-	char := line[valStart]
-	if char == '"' || char == '\'' {
-		return extractQuotedValue(line, valStart)
+	if char := line[valStart]; d.IsQuote(char) {
+		return extractQuotedValue(line, valStart, d), nil
 	}
 
 	// Unquoted value
-	return extractUnquotedValue(line, valStart), nil
+	val := extractUnquotedValue(line, valStart, d)
+	if d.StrictUnquoted {
+		if idx := d.findUnquotedSpecial(val.Raw); idx >= 0 {
+			return ValueData{}, &ParseError{
+				Column: valStart + idx + 1,
+				Kind:   ErrInvalidValue,
+				Detail: fmt.Sprintf("unescaped %q in unquoted value", val.Raw[idx]),
+			}
+		}
+	}
+
+	return val, nil
 }
 
 // findTerminator finds the closing quote, accounting for escaping.
 func FindTerminator(line string, pos int, terminator byte) int {
-	bsCount := 0 // count of consecutive backslashes
-
-	for i := pos + 1; i < len(line); i++ {
-		c := line[i]
-		if c == '\\' {
-			bsCount++
-
-			continue
-		}
-
-		if c == terminator && bsCount%2 == 0 {
-			return i
-		}
-
-		bsCount = 0
-	}
-
-	return -1
+	return DialectDefault.findClosingQuote(line, pos+1, terminator)
 }
 
 // extractQuotedValue extracts a quoted value starting at pos.
-func extractQuotedValue(line string, pos int) (ValueData, error) {
+func extractQuotedValue(line string, pos int, d *Dialect) ValueData {
 	quote := line[pos]
-	terminatorPos := FindTerminator(line, pos, quote)
+	terminatorPos := d.findClosingQuote(line, pos+1, quote)
 
 	valueType := ValueDoubleQuoted
-	if quote == '\'' {
+
+	switch quote {
+	case '\'':
 		valueType = ValueSingleQuoted
+	case '`':
+		valueType = ValueBacktickQuoted
 	}
 
 	if terminatorPos < 0 {
@@ -120,11 +182,12 @@ func extractQuotedValue(line string, pos int) (ValueData, error) {
 		return ValueData{
 			Raw:          raw,
 			Content:      content,
+			Value:        d.Decode(content, quote),
 			Start:        pos,
 			End:          len(line) - 1,
 			Type:         valueType,
 			IsTerminated: false,
-		}, nil
+		}
 	}
 
 	// Properly terminated quote
@@ -134,21 +197,29 @@ func extractQuotedValue(line string, pos int) (ValueData, error) {
 	return ValueData{
 		Raw:          raw,
 		Content:      content,
+		Value:        d.Decode(content, quote),
 		Start:        pos,
 		End:          terminatorPos,
 		Type:         valueType,
 		IsTerminated: true,
-	}, nil
+	}
 }
 
 // extractUnquotedValue extracts an unquoted value starting at pos.
-func extractUnquotedValue(line string, pos int) ValueData {
-	valEnd := common.UntilSpace(line, pos)
+// The value runs until an inline comment or the end of the line, trailing whitespace is trimmed.
+func extractUnquotedValue(line string, pos int, d *Dialect) ValueData {
+	valEnd := len(line)
+	if commentIdx := d.findInlineComment(line, pos); commentIdx >= 0 {
+		valEnd = commentIdx
+	}
+
+	valEnd = common.SkipSpacesBack(line, valEnd-1) + 1
 	raw := line[pos:valEnd]
 
 	return ValueData{
 		Raw:          raw,
 		Content:      raw, // For unquoted, raw and content are the same
+		Value:        d.Decode(raw, 0),
 		Start:        pos,
 		End:          valEnd - 1,
 		Type:         ValueUnquoted,
@@ -156,13 +227,54 @@ func extractUnquotedValue(line string, pos int) ValueData {
 	}
 }
 
+// InlineComment returns the text of the inline comment in the part of a line after a value,
+// without '#' and surrounding whitespace.
+// Returns an empty string if there is no comment.
+func InlineComment(suffix string) string {
+	text := strings.TrimSpace(suffix)
+	if !strings.HasPrefix(text, "#") {
+		return ""
+	}
+
+	return strings.TrimSpace(text[1:])
+}
+
+// ParseBareKey parses a line with a key without '=' ("KEY" or "export KEY"),
+// optionally followed by a comment.
+func ParseBareKey(line string) (KeyData, error) {
+	keyStart := common.SkipSpaces(line, 0)
+	if strings.HasPrefix(line[keyStart:], "export ") {
+		keyStart = common.SkipSpaces(line, keyStart+len("export "))
+	}
+
+	keyEnd := common.UntilSpace(line, keyStart)
+	if keyEnd == keyStart {
+		return KeyData{}, &ParseError{Column: keyStart + 1, Kind: ErrNoKey, Detail: "empty line"}
+	}
+
+	if restStart := common.SkipSpaces(line, keyEnd); restStart < len(line) && line[restStart] != '#' {
+		return KeyData{}, &ParseError{Column: restStart + 1, Kind: ErrMissingEquals, Detail: "not a bare key"}
+	}
+
+	return KeyData{
+		Key:   line[keyStart:keyEnd],
+		Start: keyStart,
+		End:   keyEnd - 1,
+	}, nil
+}
+
 // ParseVariable parses a line for a variable assignment (KEY=VALUE)
+// the way DialectDefault does.
 // Returns variable data and error.
 func ParseVariable(line string) (VariableData, error) {
+	return parseVariable(line, &DialectDefault)
+}
+
+func parseVariable(line string, d *Dialect) (VariableData, error) {
 	// Find equals sign
 	equalIdx := strings.IndexByte(line, '=')
 	if equalIdx == -1 {
-		return VariableData{}, errors.New("no equals sign found, no variable declaration")
+		return VariableData{}, &ParseError{Column: common.SkipSpaces(line, 0) + 1, Kind: ErrMissingEquals}
 	}
 
 	// Extract key
@@ -172,7 +284,7 @@ func ParseVariable(line string) (VariableData, error) {
 	}
 
 	// Extract value
-	val, err := ExtractValue(line, equalIdx)
+	val, err := extractValue(line, equalIdx, d)
 	if err != nil {
 		return VariableData{}, err
 	}
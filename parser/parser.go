@@ -3,6 +3,7 @@ package parser
 import (
 	"errors"
 	"log/slog"
+	"strings"
 
 	"github.com/4nd3r5on/go-envfile/common"
 )
@@ -10,6 +11,7 @@ import (
 var DefaultConfig = &Config{
 	Logger:         slog.Default(),
 	IgnoreSections: false,
+	Dialect:        DialectDefault,
 }
 
 type Parser struct {
@@ -18,10 +20,13 @@ type Parser struct {
 	currentSection         *common.SectionData
 	unterminatedValueLines int
 	terminator             byte
+	lineNum                int // number of parsed lines
 }
 
+// New creates a parser with a copy of DefaultConfig modified by options.
 func New(options ...Option) *Parser {
-	p := &Parser{Config: DefaultConfig}
+	cfg := *DefaultConfig
+	p := &Parser{Config: &cfg}
 
 	for _, option := range options {
 		option(p.Config)
@@ -32,7 +37,19 @@ func New(options ...Option) *Parser {
 
 // ParseLine takes as an input line from an environment file and outputs parsed line
 // Lines from the file must be passed sequentially.
+// Errors are *ParseError with the line number counted from the first call.
 func (p *Parser) ParseLine(line string) (common.ParsedLine, error) {
+	p.lineNum++
+
+	parsed, err := p.parseLine(line)
+	if err != nil {
+		return common.ParsedLine{}, p.lineError(err, line)
+	}
+
+	return parsed, nil
+}
+
+func (p *Parser) parseLine(line string) (common.ParsedLine, error) {
 	if p.unterminatedValueLines > 0 {
 		return p.handleUnterminatedValue(line)
 	}
@@ -52,7 +69,7 @@ func (p *Parser) ParseLine(line string) (common.ParsedLine, error) {
 
 // handleUnterminatedValue processes continuation lines for unterminated multi-line values.
 func (p *Parser) handleUnterminatedValue(line string) (common.ParsedLine, error) {
-	terminator := FindTerminator(line, 0, p.terminator)
+	terminator := p.Dialect.findClosingQuote(line, 0, p.terminator)
 
 	if terminator < 0 {
 		// Value continues on next line
@@ -62,7 +79,8 @@ func (p *Parser) handleUnterminatedValue(line string) (common.ParsedLine, error)
 			Type:    common.LineTypeVal,
 			RawLine: line,
 			VariableValPart: &common.VariableValPartData{
-				Value:        line,
+				Value:        p.Dialect.Decode(line, p.terminator),
+				RawValue:     line,
 				Suffix:       "",
 				IsTerminated: false,
 				Quote:        p.terminator,
@@ -83,10 +101,12 @@ func (p *Parser) handleUnterminatedValue(line string) (common.ParsedLine, error)
 		Type:    common.LineTypeVal,
 		RawLine: line,
 		VariableValPart: &common.VariableValPartData{
-			Value:        val,
-			Suffix:       suffix,
-			IsTerminated: true,
-			Quote:        p.terminator,
+			Value:         p.Dialect.Decode(val, p.terminator),
+			RawValue:      val,
+			Suffix:        suffix,
+			InlineComment: InlineComment(suffix),
+			IsTerminated:  true,
+			Quote:         p.terminator,
 		},
 		UnterminatedValueLines: p.unterminatedValueLines + 1,
 	}
@@ -159,11 +179,19 @@ func (p *Parser) handleRawLine(line string) common.ParsedLine {
 
 // handleVariableLine processes variable assignment lines.
 func (p *Parser) handleVariableLine(line string) (common.ParsedLine, error) {
-	data, err := ParseVariable(line)
+	if p.AllowBareKeys && strings.IndexByte(line, '=') < 0 {
+		return p.handleBareKeyLine(line)
+	}
+
+	data, err := parseVariable(line, &p.Dialect)
 	if err != nil {
 		return common.ParsedLine{}, err
 	}
 
+	if err := p.checkKey(line, strings.IndexByte(line, '=')); err != nil {
+		return common.ParsedLine{}, err
+	}
+
 	if p.currentSection != nil {
 		p.currentSection.Variables[data.Key.Key] = struct{}{}
 	}
@@ -186,15 +214,71 @@ func (p *Parser) handleVariableLine(line string) (common.ParsedLine, error) {
 		Type:    common.LineTypeVar,
 		RawLine: line,
 		Variable: &common.VariableData{
-			Key:          data.Key.Key,
-			Value:        data.Value.Content,
-			Prefix:       line[:data.Value.Start],
-			Suffix:       suffix,
-			IsTerminated: data.Value.IsTerminated,
-			IsQuoted:     isQuoted,
-			Quote:        terminator,
+			Key:           data.Key.Key,
+			Value:         data.Value.Value,
+			RawValue:      data.Value.Content,
+			Prefix:        line[:data.Value.Start],
+			Suffix:        suffix,
+			InlineComment: InlineComment(suffix),
+			IsTerminated:  data.Value.IsTerminated,
+			IsQuoted:      isQuoted,
+			Quote:         terminator,
 		},
 		UnterminatedValueLines: p.unterminatedValueLines,
 		SectionData:            p.currentSection,
 	}, nil
 }
+
+// handleBareKeyLine processes lines with a key without a value.
+func (p *Parser) handleBareKeyLine(line string) (common.ParsedLine, error) {
+	key, err := ParseBareKey(line)
+	if err != nil {
+		return common.ParsedLine{}, err
+	}
+
+	if err := p.checkKey(line, key.End+1); err != nil {
+		return common.ParsedLine{}, err
+	}
+
+	if p.currentSection != nil {
+		p.currentSection.Variables[key.Key] = struct{}{}
+	}
+
+	return common.ParsedLine{
+		Type:    common.LineTypeVar,
+		RawLine: line,
+		Variable: &common.VariableData{
+			Key:           key.Key,
+			Prefix:        line[:key.End+1],
+			Suffix:        line[key.End+1:],
+			InlineComment: InlineComment(line[key.End+1:]),
+			IsTerminated:  true,
+			IsBare:        true,
+		},
+		SectionData: p.currentSection,
+	}, nil
+}
+
+// checkKey validates the key before keyEnd ('=' or the end of a bare key) in strict mode.
+func (p *Parser) checkKey(line string, keyEnd int) error {
+	if !p.StrictKeys {
+		return nil
+	}
+
+	_, err := ExtractKeyStrict(line, keyEnd, p.Dialect.KeyChars)
+
+	return err
+}
+
+// lineError adds the current line to a *ParseError.
+func (p *Parser) lineError(err error, line string) error {
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		return &ParseError{Line: p.lineNum, Text: line, Kind: err}
+	}
+
+	parseErr.Line = p.lineNum
+	parseErr.Text = line
+
+	return parseErr
+}
@@ -0,0 +1,335 @@
+package parser_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+func TestNewConfigIsolation(t *testing.T) {
+	const line = "# [SECTION: db]"
+
+	ignoring := parser.New(parser.SetIgnoreSections(true))
+	regular := parser.New()
+
+	if parser.DefaultConfig.IgnoreSections {
+		t.Fatal("DefaultConfig modified by options")
+	}
+
+	got, err := regular.ParseLine(line)
+	if err != nil {
+		t.Fatalf("ParseLine() failed: %v", err)
+	}
+
+	if got.Type != common.LineTypeSectionStart {
+		t.Errorf("ParseLine() type = %v, want section start", got.Type)
+	}
+
+	if !ignoring.IgnoreSections {
+		t.Error("option not applied to its own parser")
+	}
+}
+
+func TestParseLineEmptyAndBare(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		options []parser.Option
+		want    common.VariableData
+		wantErr bool
+	}{
+		{
+			name: "empty value",
+			line: "KEY=",
+			want: common.VariableData{Key: "KEY", Prefix: "KEY=", IsTerminated: true},
+		},
+		{
+			name: "only spaces after equals sign",
+			line: "KEY=   ",
+			want: common.VariableData{Key: "KEY", Prefix: "KEY=", Suffix: "   ", IsTerminated: true},
+		},
+		{
+			name: "empty double quoted value",
+			line: `KEY=""`,
+			want: common.VariableData{Key: "KEY", Prefix: "KEY=", IsTerminated: true, IsQuoted: true, Quote: '"'},
+		},
+		{
+			name: "empty single quoted value",
+			line: "export KEY=''",
+			want: common.VariableData{Key: "KEY", Prefix: "export KEY=", IsTerminated: true, IsQuoted: true, Quote: '\''},
+		},
+		{
+			name:    "bare key",
+			line:    "KEY",
+			options: []parser.Option{parser.SetAllowBareKeys(true)},
+			want:    common.VariableData{Key: "KEY", Prefix: "KEY", IsTerminated: true, IsBare: true},
+		},
+		{
+			name:    "bare key with export and comment",
+			line:    "export KEY # from CI",
+			options: []parser.Option{parser.SetAllowBareKeys(true)},
+			want: common.VariableData{
+				Key:           "KEY",
+				Prefix:        "export KEY",
+				Suffix:        " # from CI",
+				InlineComment: "from CI",
+				IsTerminated:  true,
+				IsBare:        true,
+			},
+		},
+		{
+			name:    "bare key not allowed",
+			line:    "KEY",
+			wantErr: true,
+		},
+		{
+			name:    "several words without equals sign",
+			line:    "KEY OTHER",
+			options: []parser.Option{parser.SetAllowBareKeys(true)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.New(tt.options...).ParseLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Variable == nil || *got.Variable != tt.want {
+				t.Errorf("ParseLine() variable = %+v, want %+v", got.Variable, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineUnquotedValues(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want common.VariableData
+	}{
+		{
+			name: "value with spaces",
+			line: "GREETING=hello world",
+			want: common.VariableData{Key: "GREETING", Value: "hello world", RawValue: "hello world", Prefix: "GREETING="},
+		},
+		{
+			name: "trailing whitespace trimmed",
+			line: "GREETING=hello world \t\n",
+			want: common.VariableData{
+				Key:      "GREETING",
+				Value:    "hello world",
+				RawValue: "hello world",
+				Prefix:   "GREETING=",
+				Suffix:   " \t\n",
+			},
+		},
+		{
+			name: "hash inside a word",
+			line: "URL=http://x#frag",
+			want: common.VariableData{Key: "URL", Value: "http://x#frag", RawValue: "http://x#frag", Prefix: "URL="},
+		},
+		{
+			name: "inline comment",
+			line: "URL=x # comment\n",
+			want: common.VariableData{
+				Key:           "URL",
+				Value:         "x",
+				RawValue:      "x",
+				Prefix:        "URL=",
+				Suffix:        " # comment\n",
+				InlineComment: "comment",
+			},
+		},
+		{
+			name: "inline comment after tab",
+			line: "KEY=a b\t#comment",
+			want: common.VariableData{
+				Key:           "KEY",
+				Value:         "a b",
+				RawValue:      "a b",
+				Prefix:        "KEY=",
+				Suffix:        "\t#comment",
+				InlineComment: "comment",
+			},
+		},
+		{
+			name: "only inline comment",
+			line: "KEY=  # comment",
+			want: common.VariableData{Key: "KEY", Prefix: "KEY=", Suffix: "  # comment", InlineComment: "comment"},
+		},
+		{
+			name: "value starting with hash",
+			line: "KEY=#value",
+			want: common.VariableData{Key: "KEY", Value: "#value", RawValue: "#value", Prefix: "KEY="},
+		},
+		{
+			name: "inline comment after quoted value",
+			line: `KEY="a # b" # comment`,
+			want: common.VariableData{
+				Key:           "KEY",
+				Value:         "a # b",
+				RawValue:      "a # b",
+				Prefix:        "KEY=",
+				Suffix:        " # comment",
+				InlineComment: "comment",
+				IsQuoted:      true,
+				Quote:         '"',
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.IsTerminated = true
+
+			got, err := parser.New().ParseLine(tt.line)
+			if err != nil {
+				t.Fatalf("ParseLine() failed: %v", err)
+			}
+
+			if got.Variable == nil || *got.Variable != tt.want {
+				t.Errorf("ParseLine() variable = %+v, want %+v", got.Variable, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineStrictKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		options []parser.Option
+		wantKey string
+		wantErr string
+	}{
+		{name: "plain", line: "KEY=value", wantKey: "KEY"},
+		{name: "export and indentation", line: "  export\tMY_KEY2=value", wantKey: "MY_KEY2"},
+		{name: "underscore first", line: "_KEY=", wantKey: "_KEY"},
+		{name: "key named export", line: "export=1", wantKey: "export"},
+		{
+			name:    "bare key",
+			line:    "export KEY # comment",
+			options: []parser.Option{parser.SetAllowBareKeys(true)},
+			wantKey: "KEY",
+		},
+		{
+			name:    "dots and hyphens allowed by dialect",
+			line:    "my.key-name=1",
+			options: []parser.Option{parser.SetDialect(parser.DialectNode)},
+			wantKey: "my.key-name",
+		},
+		{name: "unknown prefix", line: "some prefix KEY=value", wantErr: `line 1, column 1: invalid key: unknown prefix "some prefix"`},
+		{name: "digit first", line: "export 123KEY=value", wantErr: "line 1, column 8: invalid key: starts with a digit"},
+		{name: "hyphen", line: "MY-KEY=value", wantErr: `line 1, column 3: invalid key: unexpected character '-'`},
+		{name: "dot", line: "MY.KEY=value", wantErr: `line 1, column 3: invalid key: unexpected character '.'`},
+		{name: "space before equals", line: "KEY =value", wantErr: "line 1, column 4: invalid key: whitespace before '='"},
+		{name: "no key", line: "export =value", wantErr: "line 1, column 8: no key"},
+		{
+			name:    "bare key with digit",
+			line:    "1KEY",
+			options: []parser.Option{parser.SetAllowBareKeys(true)},
+			wantErr: "line 1, column 1: invalid key: starts with a digit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]parser.Option{parser.SetStrictKeys(true)}, tt.options...)
+
+			got, err := parser.New(options...).ParseLine(tt.line)
+			if tt.wantErr != "" {
+				var parseErr *parser.ParseError
+				if !errors.As(err, &parseErr) || err.Error() != tt.wantErr {
+					t.Fatalf("ParseLine() error = %v, want %s", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseLine() failed: %v", err)
+			}
+
+			if got.Variable == nil || got.Variable.Key != tt.wantKey {
+				t.Errorf("ParseLine() variable = %+v, want key %q", got.Variable, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestParseLineStrictKeysLineNumber(t *testing.T) {
+	p := parser.New(parser.SetStrictKeys(true))
+
+	for _, line := range []string{"# comment", "A=1", `B="multi`, `line"`} {
+		if _, err := p.ParseLine(line); err != nil {
+			t.Fatalf("ParseLine(%q) failed: %v", line, err)
+		}
+	}
+
+	_, err := p.ParseLine("C D=1")
+
+	var parseErr *parser.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 5 || parseErr.Column != 1 || parseErr.Text != "C D=1" {
+		t.Errorf("ParseLine() error = %v, want ParseError at line 5, column 1", err)
+	}
+
+	if !errors.Is(err, parser.ErrInvalidKey) {
+		t.Errorf("ParseLine() error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantKind   error
+		wantColumn int
+		wantMsg    string
+	}{
+		{name: "equals at start", line: "=value", wantKind: parser.ErrNoKey, wantColumn: 1, wantMsg: "line 1, column 1: no key: '=' at start of line"},
+		{name: "spaces before equals", line: "  =value", wantKind: parser.ErrNoKey, wantColumn: 3},
+		{name: "no equals", line: "  KEY value", wantKind: parser.ErrMissingEquals, wantColumn: 3, wantMsg: "line 1, column 3: missing '='"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.New().ParseLine(tt.line)
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("ParseLine() error = %v, want %v", err, tt.wantKind)
+			}
+
+			var parseErr *parser.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseLine() error = %v, want ParseError", err)
+			}
+
+			if parseErr.Line != 1 || parseErr.Column != tt.wantColumn || parseErr.Text != tt.line {
+				t.Errorf("ParseLine() error = %+v, want line 1, column %d", parseErr, tt.wantColumn)
+			}
+
+			if tt.wantMsg != "" && err.Error() != tt.wantMsg {
+				t.Errorf("ParseLine() error = %q, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	err := &parser.ParseError{
+		Path:   ".env",
+		Line:   3,
+		Column: 5,
+		Text:   "A=secret",
+		Kind:   parser.ErrInvalidKey,
+		Detail: "starts with a digit",
+	}
+
+	if want := ".env:3:5: invalid key: starts with a digit"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
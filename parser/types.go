@@ -6,12 +6,14 @@ const (
 	ValueUnquoted ValueType = iota
 	ValueSingleQuoted
 	ValueDoubleQuoted
+	ValueBacktickQuoted
 )
 
 // ValueData holds information about an extracted value.
 type ValueData struct {
 	Raw          string    // The raw value including quotes if present
 	Content      string    // The actual content (without quotes)
+	Value        string    // Content with escape sequences decoded (double-quoted values only)
 	Start        int       // Start position in the line
 	End          int       // End position in the line
 	Type         ValueType // Whether the value was quoted and with what
@@ -37,6 +39,8 @@ func GetQuoteFromValType(t ValueType) (isQuoted bool, quote byte) {
 		return true, '\''
 	case ValueDoubleQuoted:
 		return true, '"'
+	case ValueBacktickQuoted:
+		return true, '`'
 	default:
 		return false, 0
 	}
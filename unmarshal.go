@@ -0,0 +1,309 @@
+package envfile
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+)
+
+// UnmarshalError describes a value that couldn't be stored into a struct field.
+type UnmarshalError struct {
+	Key   string
+	Line  int    // Line number of the variable definition (starting from 1), 0 for a default value
+	Field string // Struct field name
+	Type  reflect.Type
+	Err   error
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf(
+		"cannot unmarshal %s (line %d) into field %s of type %s: %v",
+		e.Key, e.Line, e.Field, e.Type, e.Err,
+	)
+}
+
+func (e *UnmarshalError) Unwrap() error { return e.Err }
+
+// Unmarshal stores variables from parsed lines into the struct pointed to by v.
+// It's the inverse of UpdatesFromStruct and uses the same conventions:
+// the "env" tag specifies the variable name, if absent the field name in UPPER_SNAKE_CASE is used,
+// fields tagged with "-" are skipped, embedded structs are flattened.
+// Fields without a matching variable get the value of the "default" tag if present,
+// otherwise they are left untouched.
+//
+// Supported field types: strings, bools, ints, uints, floats, time.Duration,
+// encoding.TextUnmarshaler implementations, pointers, slices and maps of those.
+// An empty value sets a pointer to nil.
+// Slice items and map entries are separated with "," (can be changed with the "sep" tag),
+// map keys and values are separated with ":", items in Go double quotes are unquoted.
+func Unmarshal(lines []common.ParsedLine, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("unmarshal target must be a non-nil pointer to a struct")
+	}
+
+	target := unwrapToStruct(v)
+	if target == nil {
+		return errors.New("unmarshal target must be a non-nil pointer to a struct")
+	}
+
+	vars := make(map[string]variable)
+	for _, def := range collectVariables(lines, nil) {
+		if def.Unset {
+			delete(vars, def.Key)
+
+			continue
+		}
+
+		vars[def.Key] = def
+	}
+
+	var err error
+
+	walkStruct(*target, func(field reflectField) {
+		if err != nil {
+			return
+		}
+
+		tag, ok := parseEnvTag(field, "env")
+		if !ok {
+			return
+		}
+
+		def, exists := vars[tag.name]
+		if !exists {
+			if !tag.hasDefault {
+				return
+			}
+
+			def = variable{Key: tag.name, Value: tag.defaultValue, LineIdx: -1}
+		}
+
+		if setErr := setValue(field.value, def.Value, tag.sep); setErr != nil {
+			err = &UnmarshalError{
+				Key:   tag.name,
+				Line:  def.LineIdx + 1,
+				Field: field.typ.Name,
+				Type:  field.typ.Type,
+				Err:   setErr,
+			}
+		}
+	})
+
+	return err
+}
+
+// setValue converts s into the type of v and stores it.
+func setValue(v reflect.Value, s, sep string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if s == "" {
+			v.SetZero()
+
+			return nil
+		}
+
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s, sep); err != nil {
+			return err
+		}
+
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	case reflect.Slice:
+		return setSlice(v, s, sep)
+	case reflect.Map:
+		return setMap(v, s, sep)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// setSlice splits s by sep and stores converted items into slice v.
+func setSlice(v reflect.Value, s, sep string) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes([]byte(s))
+
+		return nil
+	}
+
+	items := splitList(s, sep, false)
+	slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+
+	for i, item := range items {
+		if err := setValue(slice.Index(i), unquoteItem(item), sep); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	v.Set(slice)
+
+	return nil
+}
+
+// setMap splits s into "key:value" entries by sep and stores them into map v.
+func setMap(v reflect.Value, s, sep string) error {
+	items := splitList(s, sep, true)
+	m := reflect.MakeMapWithSize(v.Type(), len(items))
+
+	for _, item := range items {
+		rawKey, rawVal, found := cutMapEntry(item)
+		if !found {
+			return fmt.Errorf("map entry %q: missing %q", item, mapKeyValueSep)
+		}
+
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := setValue(key, rawKey, sep); err != nil {
+			return fmt.Errorf("map key %q: %w", rawKey, err)
+		}
+
+		val := reflect.New(v.Type().Elem()).Elem()
+		if err := setValue(val, rawVal, sep); err != nil {
+			return fmt.Errorf("map value for %q: %w", rawKey, err)
+		}
+
+		m.SetMapIndex(key, val)
+	}
+
+	v.Set(m)
+
+	return nil
+}
+
+// cutMapEntry splits a "key:value" entry at the first ':' outside a quoted key,
+// trims and unquotes both parts.
+func cutMapEntry(item string) (key, val string, found bool) {
+	keyEnd := 0
+	if prefix, err := strconv.QuotedPrefix(item); err == nil {
+		keyEnd = len(prefix)
+	}
+
+	idx := strings.Index(item[keyEnd:], mapKeyValueSep)
+	if idx < 0 {
+		return "", "", false
+	}
+
+	idx += keyEnd
+
+	return unquoteItem(strings.TrimSpace(item[:idx])),
+		unquoteItem(strings.TrimSpace(item[idx+len(mapKeyValueSep):])), true
+}
+
+// splitList splits s by sep trimming spaces around items.
+// Separators inside an item or a map value (if isMap) written in Go double quotes are skipped,
+// the quotes are kept for unquoteItem.
+// Returns no items for an empty string.
+func splitList(s, sep string, isMap bool) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	var items []string
+
+	start := 0
+	canQuote := true // at the start of an item or a map value
+	seenKeySep := false
+
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], sep):
+			items = append(items, strings.TrimSpace(s[start:i]))
+			i += len(sep)
+			start, canQuote, seenKeySep = i, true, false
+
+			continue
+		case s[i] == '"' && canQuote:
+			if prefix, err := strconv.QuotedPrefix(s[i:]); err == nil {
+				i += len(prefix)
+				canQuote = false
+
+				continue
+			}
+		case isMap && !seenKeySep && strings.HasPrefix(s[i:], mapKeyValueSep):
+			i += len(mapKeyValueSep)
+			canQuote, seenKeySep = true, true
+
+			continue
+		}
+
+		if !unicode.IsSpace(rune(s[i])) {
+			canQuote = false
+		}
+
+		i++
+	}
+
+	return append(items, strings.TrimSpace(s[start:]))
+}
+
+// unquoteItem unquotes an item written in Go double quotes, other items are returned as is.
+func unquoteItem(item string) string {
+	if !strings.HasPrefix(item, `"`) {
+		return item
+	}
+
+	unquoted, err := strconv.Unquote(item)
+	if err != nil {
+		return item
+	}
+
+	return unquoted
+}
@@ -0,0 +1,130 @@
+package envfile_test
+
+import (
+	"bufio"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4nd3r5on/go-envfile"
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+type UnmarshalEmbedded struct {
+	LogLevel string `env:"LOG_LEVEL"`
+}
+
+type unmarshalConfig struct {
+	UnmarshalEmbedded
+
+	Host     string
+	Port     int     `env:"PORT"`
+	Debug    bool    `env:"DEBUG"`
+	Ratio    float64 `env:"RATIO"`
+	Timeout  time.Duration
+	Tags     []string       `env:"TAGS" sep:";"`
+	Ports    []uint16       `env:"PORTS"`
+	Limits   map[string]int `env:"LIMITS"`
+	Addr     netip.Addr     `env:"ADDR"`
+	Optional *int           `env:"OPTIONAL"`
+	Missing  string         `env:"MISSING"`
+	Skipped  string         `env:"-"`
+}
+
+func parseLines(t *testing.T, content string) []common.ParsedLine {
+	t.Helper()
+
+	lines, err := envfile.Parse(envfile.NewParser(), bufio.NewScanner(strings.NewReader(content)))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	return lines
+}
+
+func TestUnmarshal(t *testing.T) {
+	lines := parseLines(t, `LOG_LEVEL=debug
+HOST="example.com"
+PORT=8080
+DEBUG=true
+RATIO=0.5
+TIMEOUT=1m30s
+TAGS="a; b;c"
+PORTS=80,443
+LIMITS=read:10,write:5
+ADDR=127.0.0.1
+OPTIONAL=7
+SKIPPED=nope
+`)
+
+	got := unmarshalConfig{Missing: "kept"}
+	if err := envfile.Unmarshal(lines, &got); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	optional := 7
+	want := unmarshalConfig{
+		UnmarshalEmbedded: UnmarshalEmbedded{LogLevel: "debug"},
+		Host:              "example.com",
+		Port:              8080,
+		Debug:             true,
+		Ratio:             0.5,
+		Timeout:           90 * time.Second,
+		Tags:              []string{"a", "b", "c"},
+		Ports:             []uint16{80, 443},
+		Limits:            map[string]int{"read": 10, "write": 5},
+		Addr:              netip.MustParseAddr("127.0.0.1"),
+		Optional:          &optional,
+		Missing:           "kept",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	lines := parseLines(t, "HOST=example.com\nPORT=abc\n")
+
+	var cfg unmarshalConfig
+
+	err := envfile.Unmarshal(lines, &cfg)
+
+	var unmarshalErr *envfile.UnmarshalError
+	if !errors.As(err, &unmarshalErr) {
+		t.Fatalf("Unmarshal() error = %v, want UnmarshalError", err)
+	}
+
+	if unmarshalErr.Key != "PORT" || unmarshalErr.Line != 2 || unmarshalErr.Field != "Port" {
+		t.Errorf("UnmarshalError = %+v, want key PORT, line 2, field Port", unmarshalErr)
+	}
+}
+
+func TestUnmarshalEmptyPointers(t *testing.T) {
+	type config struct {
+		Count   *int           `env:"COUNT"`
+		Enabled *bool          `env:"ENABLED"`
+		Timeout *time.Duration `env:"TIMEOUT"`
+	}
+
+	count := 1
+	got := config{Count: &count}
+
+	if err := envfile.Unmarshal(parseLines(t, "COUNT=\nENABLED=\nTIMEOUT=\"\"\n"), &got); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	if got.Count != nil || got.Enabled != nil || got.Timeout != nil {
+		t.Errorf("Unmarshal() = %+v, want nil pointers", got)
+	}
+}
+
+func TestUnmarshalInvalidTarget(t *testing.T) {
+	var cfg unmarshalConfig
+	if err := envfile.Unmarshal(nil, cfg); err == nil {
+		t.Fatal("Unmarshal() into non-pointer succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,558 @@
+package envfile_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4nd3r5on/go-envfile"
+	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/interpolate"
+	"github.com/4nd3r5on/go-envfile/parser"
+	"github.com/4nd3r5on/go-envfile/updater"
+)
+
+func discardOptions() envfile.UpdateFileOptions {
+	return envfile.UpdateFileOptions{Logger: slog.New(slog.DiscardHandler)}
+}
+
+func TestUpdateBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		updates []updater.Update
+		want    string
+	}{
+		{
+			name:    "update keeps formatting",
+			content: "# db\nexport DB_HOST='localhost'\nDB_PORT=5432\n",
+			updates: []updater.Update{{Key: "DB_PORT", Value: "3306"}},
+			want:    "# db\nexport DB_HOST='localhost'\nDB_PORT=3306\n",
+		},
+		{
+			name:    "add variable",
+			content: "A=1\n",
+			updates: []updater.Update{{Key: "MESSAGE", Value: "Hello World"}},
+			want:    "A=1\nMESSAGE=\"Hello World\"\n",
+		},
+		{
+			name:    "no changes",
+			content: "A=1\n",
+			updates: []updater.Update{{Key: "A", Value: "1"}},
+			want:    "A=1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := envfile.UpdateBytes([]byte(tt.content), tt.updates, discardOptions())
+			if err != nil {
+				t.Fatalf("UpdateBytes() failed: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("UpdateBytes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateStream(t *testing.T) {
+	in := strings.NewReader("A=1\nB=2\n")
+
+	// Stream position must not matter
+	if _, err := in.Seek(3, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	_, err := envfile.UpdateStream(in, &out, []updater.Update{{Key: "B", Value: "3"}}, discardOptions())
+	if err != nil {
+		t.Fatalf("UpdateStream() failed: %v", err)
+	}
+
+	if want := "A=1\nB=3\n"; out.String() != want {
+		t.Errorf("UpdateStream() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	path := writeEnvFile(t, "A=1\nB=2\n")
+
+	res, err := envfile.UpdateFile(path, []updater.Update{{Key: "B", Value: "3"}}, discardOptions())
+	if err != nil {
+		t.Fatalf("UpdateFile() failed: %v", err)
+	}
+
+	if !res.Changed {
+		t.Error("UpdateFile().Changed = false, want true")
+	}
+
+	wantChanges := updater.ChangeSet{{Key: "B", Kind: updater.ChangeUpdated, OldLine: 2, NewLine: 2}}
+	if !slices.Equal(res.Changes, wantChanges) {
+		t.Errorf("UpdateFile().Changes = %+v, want %+v", res.Changes, wantChanges)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "A=1\nB=3\n"; string(got) != want {
+		t.Errorf("UpdateFile() wrote %q, want %q", got, want)
+	}
+}
+
+func TestUpdateFileBareKeys(t *testing.T) {
+	path := writeEnvFile(t, "export INHERITED # from env\nA=1\n")
+	updates := []updater.Update{{Key: "A", Value: "2"}}
+
+	if _, err := envfile.UpdateFile(path, updates, discardOptions()); !errors.Is(err, parser.ErrMissingEquals) {
+		t.Errorf("UpdateFile() without AllowBareKeys error = %v, want ErrMissingEquals", err)
+	}
+
+	opts := discardOptions()
+	opts.AllowBareKeys = true
+
+	if _, err := envfile.UpdateFile(path, updates, opts); err != nil {
+		t.Fatalf("UpdateFile() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "export INHERITED # from env\nA=2\n"; string(got) != want {
+		t.Errorf("UpdateFile() wrote %q, want %q", got, want)
+	}
+}
+
+func TestUpdateFileDryRun(t *testing.T) {
+	const content = "A=1\nB=2\n"
+
+	path := writeEnvFile(t, content)
+	opts := discardOptions()
+	opts.DryRun = true
+	opts.Backup = true
+
+	res, err := envfile.UpdateFile(path, []updater.Update{{Key: "B", Value: "3"}}, opts)
+	if err != nil {
+		t.Fatalf("UpdateFile() failed: %v", err)
+	}
+
+	wantDiff := "--- " + path + "\n+++ " + path + "\n@@ -1,2 +1,2 @@\n A=1\n-B=2\n+B=3\n"
+	if !res.Changed || res.Diff != wantDiff {
+		t.Errorf("UpdateFile() = %+v, want changed with diff %q", res, wantDiff)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != content {
+		t.Errorf("UpdateFile() in dry-run mode modified the file: %q", got)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("UpdateFile() in dry-run mode created files: %v", entries)
+	}
+}
+
+func TestUpdateBytesDryRun(t *testing.T) {
+	const content = "A=1\nB=2\n"
+
+	opts := discardOptions()
+	opts.DryRun = true
+
+	got, res, err := envfile.UpdateBytes([]byte(content), []updater.Update{{Key: "B", Value: "3"}}, opts)
+	if err != nil {
+		t.Fatalf("UpdateBytes() failed: %v", err)
+	}
+
+	wantDiff := "--- original\n+++ updated\n@@ -1,2 +1,2 @@\n A=1\n-B=2\n+B=3\n"
+	if string(got) != content || !res.Changed || res.Diff != wantDiff {
+		t.Errorf("UpdateBytes() = %q, %+v, want unchanged content and diff %q", got, res, wantDiff)
+	}
+
+	_, _, err = envfile.UpdateBytes([]byte("A=\"1\n"), []updater.Update{{Key: "B", Value: "3"}}, opts)
+	if !errors.Is(err, parser.ErrUnterminatedQuote) {
+		t.Errorf("UpdateBytes() of unterminated value error = %v, want ErrUnterminatedQuote", err)
+	}
+
+	var precondErr *updater.PreconditionError
+
+	_, _, err = envfile.UpdateBytes([]byte(content), []updater.Update{{Key: "A", Value: "2", If: updater.CondAbsent}}, opts)
+	if !errors.As(err, &precondErr) {
+		t.Errorf("UpdateBytes() with failing precondition error = %v, want PreconditionError", err)
+	}
+}
+
+func TestUpdateStreamDryRunUnchanged(t *testing.T) {
+	opts := discardOptions()
+	opts.DryRun = true
+
+	var out bytes.Buffer
+
+	res, err := envfile.UpdateStream(strings.NewReader("A=1\n"), &out, []updater.Update{{Key: "A", Value: "1"}}, opts)
+	if err != nil {
+		t.Fatalf("UpdateStream() failed: %v", err)
+	}
+
+	if res.Changed || res.Diff != "" || out.Len() != 0 {
+		t.Errorf("UpdateStream() = %+v, output %q, want no changes", res, out.String())
+	}
+}
+
+func TestUpdateFileCreate(t *testing.T) {
+	updates := []updater.Update{
+		{Key: "A", Value: "1"},
+		{Key: "DB_HOST", Value: "localhost", Section: "db"},
+	}
+
+	t.Run("missing file without create", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".env")
+
+		if _, err := envfile.UpdateFile(path, updates, discardOptions()); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("UpdateFile() error = %v, want %v", err, fs.ErrNotExist)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".env")
+		opts := discardOptions()
+		opts.Create = true
+		opts.Order = updater.OrderUpdates
+
+		res, err := envfile.UpdateFile(path, updates, opts)
+		if err != nil {
+			t.Fatalf("UpdateFile() failed: %v", err)
+		}
+
+		if !res.Changed {
+			t.Error("UpdateFile().Changed = false, want true")
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "A=1\n# [SECTION: db]\nDB_HOST=localhost\n\n# [SECTION_END: db]\n"
+		if string(got) != want {
+			t.Errorf("UpdateFile() wrote %q, want %q", got, want)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != envfile.DefaultCreatePerm {
+			t.Errorf("created file mode = %v, want %v", info.Mode().Perm(), envfile.DefaultCreatePerm)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".env")
+		opts := discardOptions()
+		opts.Create = true
+		opts.DryRun = true
+
+		res, err := envfile.UpdateFile(path, updates[:1], opts)
+		if err != nil {
+			t.Fatalf("UpdateFile() failed: %v", err)
+		}
+
+		if !res.Changed || res.Diff == "" {
+			t.Errorf("UpdateFile() = %+v, want changed with diff", res)
+		}
+
+		if _, err = os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("UpdateFile() in dry-run mode created the file: %v", err)
+		}
+	})
+}
+
+func TestUpdateFileLocked(t *testing.T) {
+	path := writeEnvFile(t, "A=1\n")
+
+	lock, err := common.LockFile(path, 0)
+	if err != nil {
+		t.Fatalf("LockFile() failed: %v", err)
+	}
+
+	opts := discardOptions()
+	opts.LockTimeout = 20 * time.Millisecond
+
+	_, err = envfile.UpdateFile(path, []updater.Update{{Key: "A", Value: "2"}}, opts)
+
+	var lockErr *common.LockError
+	if !errors.As(err, &lockErr) || !errors.Is(err, common.ErrLockTimeout) {
+		t.Fatalf("UpdateFile() on locked file error = %v, want LockError with ErrLockTimeout", err)
+	}
+
+	opts.LockTimeout = -1
+	start := time.Now()
+
+	_, err = envfile.UpdateFile(path, []updater.Update{{Key: "A", Value: "2"}}, opts)
+	if !errors.Is(err, common.ErrLockTimeout) || time.Since(start) > time.Second {
+		t.Fatalf("UpdateFile() without waiting on locked file error = %v after %v, want ErrLockTimeout at once",
+			err, time.Since(start))
+	}
+
+	if err = lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = envfile.UpdateFile(path, []updater.Update{{Key: "A", Value: "2"}}, opts); err != nil {
+		t.Errorf("UpdateFile() after Unlock() failed: %v", err)
+	}
+}
+
+func TestUpdateFileConflict(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		conflicts int // number of attempts the file is changed during
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "retried",
+			retries:   2,
+			conflicts: 2,
+			want:      "A=2\nB=2\n",
+		},
+		{
+			name:      "retries exhausted",
+			retries:   1,
+			conflicts: 2,
+			want:      "A=1\nB=2\n",
+			wantErr:   common.ErrConflict,
+		},
+		{
+			name:      "no retries",
+			conflicts: 1,
+			want:      "A=1\nB=1\n",
+			wantErr:   common.ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeEnvFile(t, "A=1\n")
+
+			attempts := 0
+
+			envfile.SetBeforeApply(func(path string) {
+				attempts++
+				if attempts > tt.conflicts {
+					return
+				}
+
+				// Another writer not using the lock
+				content := "A=1\nB=" + strconv.Itoa(attempts) + "\n"
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatalf("failed to change env file: %v", err)
+				}
+			})
+			t.Cleanup(func() { envfile.SetBeforeApply(nil) })
+
+			opts := discardOptions()
+			opts.ConflictRetries = tt.retries
+
+			_, err := envfile.UpdateFile(path, []updater.Update{{Key: "A", Value: "2"}}, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateFile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("file content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.env")
+	link := filepath.Join(dir, ".env")
+
+	if err := os.WriteFile(target, []byte("A=1\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if _, err := envfile.UpdateFile(link, []updater.Update{{Key: "A", Value: "2"}}, discardOptions()); err != nil {
+		t.Fatalf("UpdateFile() failed: %v", err)
+	}
+
+	linkInfo, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if linkInfo.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("UpdateFile() replaced the symlink with a %v file", linkInfo.Mode())
+	}
+
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "A=2\n"; string(got) != want {
+		t.Errorf("UpdateFile() wrote %q to the target, want %q", got, want)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("target mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o640))
+	}
+}
+
+func TestUpdateRedactsLogs(t *testing.T) {
+	var logs bytes.Buffer
+
+	opts := envfile.UpdateFileOptions{
+		Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	updates := []updater.Update{
+		{Key: "API_KEY", Value: "old-secret-value"},
+		{Key: "DB_PASSWORD", Value: "new-secret-value"},
+	}
+
+	if _, _, err := envfile.UpdateBytes([]byte("API_KEY=1\n"), updates, opts); err != nil {
+		t.Fatalf("UpdateBytes() failed: %v", err)
+	}
+
+	if strings.Contains(logs.String(), "secret-value") {
+		t.Errorf("logs contain a secret value:\n%s", logs.String())
+	}
+
+	if !strings.Contains(logs.String(), common.RedactedValue) {
+		t.Errorf("logs don't contain redacted values:\n%s", logs.String())
+	}
+}
+
+func TestUpdateRedactsDiff(t *testing.T) {
+	opts := discardOptions()
+	opts.DryRun = true
+
+	updates := []updater.Update{{Key: "API_KEY", Value: "new-secret"}, {Key: "PORT", Value: "2"}}
+
+	_, res, err := envfile.UpdateBytes([]byte("API_KEY=old-secret\nPORT=1\n"), updates, opts)
+	if err != nil {
+		t.Fatalf("UpdateBytes() failed: %v", err)
+	}
+
+	if !res.Changed || strings.Contains(res.Diff, "secret") || !strings.Contains(res.Diff, "+PORT=2") {
+		t.Errorf("UpdateBytes().Diff = %q, want changes with redacted secrets", res.Diff)
+	}
+}
+
+func TestUpdateBytesDialects(t *testing.T) {
+	const content = "UNQUOTED=x\nDOUBLE=\"x\"\nSINGLE='x'\n"
+
+	dialects := []parser.Dialect{
+		parser.DialectDefault,
+		parser.DialectCompose,
+		parser.DialectNode,
+		parser.DialectPython,
+		parser.DialectShell,
+	}
+	values := []string{"with space", "a #b", "it's", `say "hi"`, `back\slash`, "line\nbreak", "#x", "pa$word", "x$$y"}
+
+	for _, dialect := range dialects {
+		for _, value := range values {
+			updates := []updater.Update{
+				{Key: "UNQUOTED", Value: value},
+				{Key: "DOUBLE", Value: value},
+				{Key: "SINGLE", Value: value},
+				{Key: "ADDED", Value: value},
+			}
+
+			opts := discardOptions()
+			opts.Dialect = &dialect
+
+			got, _, err := envfile.UpdateBytes([]byte(content), updates, opts)
+			if err != nil {
+				t.Fatalf("%s: UpdateBytes() failed: %v", dialect.Name, err)
+			}
+
+			lines, err := envfile.Parse(
+				envfile.NewParser(parser.SetDialect(dialect)),
+				bufio.NewScanner(bytes.NewReader(got)),
+			)
+			if err != nil {
+				t.Fatalf("%s: Parse(%q) failed: %v", dialect.Name, got, err)
+			}
+
+			vars, err := envfile.LinesToExpandedMap(lines, interpolate.SetDialect(dialect))
+			if err != nil {
+				t.Fatalf("%s: LinesToExpandedMap(%q) failed: %v", dialect.Name, got, err)
+			}
+
+			if len(vars) != len(updates) {
+				t.Errorf("%s: %d variables read back from %q, want %d", dialect.Name, len(vars), got, len(updates))
+			}
+
+			for key, v := range vars {
+				if v != value {
+					t.Errorf("%s: %s = %q read back from %q, want %q", dialect.Name, key, v, got, value)
+				}
+			}
+		}
+	}
+}
+
+func TestParseErrorsHavePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("A=1\nB='open\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := envfile.ParseFile(path, envfile.NewParser())
+
+	var parseErr *parser.ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, parser.ErrUnterminatedQuote) {
+		t.Fatalf("ParseFile() error = %v, want ParseError with ErrUnterminatedQuote", err)
+	}
+
+	if parseErr.Path != path || parseErr.Line != 2 || parseErr.Column != 3 {
+		t.Errorf("ParseFile() error = %+v, want %s at line 2, column 3", parseErr, path)
+	}
+
+	_, err = envfile.UpdateFile(path, []updater.Update{{Key: "A", Value: "2"}}, discardOptions())
+	if !errors.As(err, &parseErr) || parseErr.Path != path || !errors.Is(err, parser.ErrUnterminatedQuote) {
+		t.Errorf("UpdateFile() error = %v, want ParseError for %s", err, path)
+	}
+}
@@ -0,0 +1,237 @@
+package updater
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/4nd3r5on/go-envfile/common"
+)
+
+// ChangeKind describes what an update did with a variable.
+type ChangeKind uint8
+
+const (
+	// ChangeUnchanged means the variable is already up to date.
+	ChangeUnchanged ChangeKind = iota
+	// ChangeAdded means the variable didn't exist and was added.
+	ChangeAdded
+	// ChangeUpdated means the value was changed in place.
+	ChangeUpdated
+	// ChangeMoved means the variable was moved to another section, its value may have changed too.
+	ChangeMoved
+	// ChangeRemoved means the variable was deleted.
+	ChangeRemoved
+	// ChangeCommented means the variable was commented out.
+	ChangeCommented
+	// ChangeUncommented means a commented out assignment was uncommented.
+	ChangeUncommented
+	// ChangeRenamed means the key was changed to Change.NewKey.
+	ChangeRenamed
+	// ChangeNotFound means there was nothing to delete, comment, uncomment or rename.
+	ChangeNotFound
+	// ChangeSkipped means the update mode didn't allow the change (see UpdateMode).
+	ChangeSkipped
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeUnchanged:
+		return "unchanged"
+	case ChangeAdded:
+		return "added"
+	case ChangeUpdated:
+		return "updated"
+	case ChangeMoved:
+		return "moved"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeCommented:
+		return "commented"
+	case ChangeUncommented:
+		return "uncommented"
+	case ChangeRenamed:
+		return "renamed"
+	case ChangeNotFound:
+		return "not found"
+	case ChangeSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// Change describes what happened to a single key.
+// Line numbers start from 1, 0 means the variable is absent in that version of the file.
+// For ChangeCommented NewLine points at the commented out definition,
+// for ChangeUncommented OldLine points at the commented out assignment.
+type Change struct {
+	Key        string
+	Kind       ChangeKind
+	NewKey     string // for ChangeRenamed
+	OldLine    int64
+	NewLine    int64
+	OldSection string
+	NewSection string
+}
+
+// ChangeSet lists changes in the order of updates.
+// A variable removed because another one was renamed over it (Update.Overwrite)
+// follows the rename.
+type ChangeSet []Change
+
+// Changed reports whether any change modifies the file.
+func (cs ChangeSet) Changed() bool {
+	return slices.ContainsFunc(cs, func(c Change) bool {
+		return c.Kind != ChangeUnchanged && c.Kind != ChangeNotFound && c.Kind != ChangeSkipped
+	})
+}
+
+// Get returns the change for a key.
+func (cs ChangeSet) Get(key string) (Change, bool) {
+	idx := slices.IndexFunc(cs, func(c Change) bool { return c.Key == key })
+	if idx < 0 {
+		return Change{}, false
+	}
+
+	return cs[idx], true
+}
+
+// anchorPart tells which part of a patched line the variable ends up in.
+type anchorPart uint8
+
+const (
+	anchorInsert anchorPart = iota
+	anchorLine
+	anchorInsertAfter
+)
+
+// lineAnchor is the position of a variable in the patched output:
+// a line of the original file, a part of its patch and a byte offset inside that part.
+type lineAnchor struct {
+	line   int64
+	part   anchorPart
+	offset int
+}
+
+type trackedChange struct {
+	Change
+
+	order       int
+	overwritten bool        // removed by a rename, has no update of its own
+	anchor      *lineAnchor // nil if the variable isn't in the output
+}
+
+// trackChange records a change, replacing an earlier one for the same key.
+func (u *Updater) trackChange(change Change, anchor *lineAnchor) {
+	order, hasUpdate := u.updateOrder[change.Key]
+	if !hasUpdate {
+		// Variable overwritten by a rename, goes after the rename
+		order = u.updateOrder[u.renameTargets[change.Key]]
+	}
+
+	u.changes[change.Key] = &trackedChange{
+		Change:      change,
+		order:       order,
+		overwritten: !hasUpdate,
+		anchor:      anchor,
+	}
+}
+
+// setAnchor sets the output position of a variable placed at EOF.
+func (u *Updater) setAnchor(key string, anchor lineAnchor) {
+	if tracked, exists := u.changes[key]; exists {
+		tracked.anchor = &anchor
+	}
+}
+
+// Changes returns changes made by the processed updates.
+// Must be called after HandleEOF.
+func (u *Updater) Changes() ChangeSet {
+	tracked := slices.Collect(maps.Values(u.changes))
+	slices.SortFunc(tracked, func(a, b *trackedChange) int {
+		if c := cmp.Compare(a.order, b.order); c != 0 {
+			return c
+		}
+
+		if a.overwritten != b.overwritten {
+			if a.overwritten {
+				return 1
+			}
+
+			return -1
+		}
+
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	patchLines := slices.Sorted(maps.Keys(u.patchMap))
+	changes := make(ChangeSet, len(tracked))
+
+	for i, t := range tracked {
+		changes[i] = t.Change
+		if t.anchor != nil {
+			changes[i].NewLine = u.outputLine(*t.anchor, patchLines) + 1
+		}
+	}
+
+	return changes
+}
+
+// outputLine returns the index of the anchored line in the patched output.
+// patchLines are sorted indexes of patched lines.
+func (u *Updater) outputLine(anchor lineAnchor, patchLines []int64) int64 {
+	out := min(anchor.line, u.eofLine)
+
+	for _, idx := range patchLines {
+		if idx >= anchor.line {
+			break
+		}
+
+		out += u.patchedLineCount(u.patchMap[idx]) - 1
+	}
+
+	patch := u.patchMap[anchor.line]
+
+	switch anchor.part {
+	case anchorInsert:
+		return out + int64(strings.Count(patch.Insert[:anchor.offset], "\n"))
+	case anchorLine:
+		return out + countLines(patch.ShouldInsert, patch.Insert)
+	default:
+		out += countLines(patch.ShouldInsert, patch.Insert)
+		if !patch.RemoveLine && anchor.line < u.eofLine {
+			out++
+		}
+
+		return out + int64(strings.Count(patch.InsertAfter[:anchor.offset], "\n"))
+	}
+}
+
+// patchedLineCount returns the number of output lines produced by a patched line.
+func (u *Updater) patchedLineCount(patch common.Patch) int64 {
+	n := countLines(patch.ShouldInsert, patch.Insert) + countLines(patch.ShouldInsertAfter, patch.InsertAfter)
+	if !patch.RemoveLine && patch.LineIdx < u.eofLine {
+		n++
+	}
+
+	return n
+}
+
+func countLines(enabled bool, content string) int64 {
+	if !enabled {
+		return 0
+	}
+
+	return int64(strings.Count(content, "\n"))
+}
+
+// sectionOf returns the name of the section a parsed line belongs to.
+func sectionOf(line common.ParsedLine) string {
+	if line.SectionData == nil {
+		return ""
+	}
+
+	return line.SectionData.Name
+}
@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// PreconditionError lists keys whose update precondition failed.
+type PreconditionError struct {
+	Keys []string
+}
+
+func (e *PreconditionError) Error() string {
+	return "precondition failed for keys: " + strings.Join(e.Keys, ", ")
+}
+
+// Holds reports whether the condition holds for a variable.
+// isDefined tells if the variable is defined, current is its value.
+func (c Condition) Holds(isDefined bool, current, expected string) bool {
+	switch c {
+	case CondAbsent:
+		return !isDefined
+	case CondPresent:
+		return isDefined
+	case CondEquals:
+		return isDefined && current == expected
+	default:
+		return true
+	}
+}
+
+// checkCondition evaluates the precondition of an update.
+// If it fails, the key is recorded and the update is dropped.
+func (u *Updater) checkCondition(update Update, isDefined bool, current string) bool {
+	if update.If.Holds(isDefined, current, update.IfValue) {
+		return true
+	}
+
+	u.Logger.Debug("precondition failed", "key", update.Key, "condition", update.If, "defined", isDefined)
+
+	u.failedConditions = append(u.failedConditions, update.Key)
+	delete(u.updateMap, update.Key)
+
+	return false
+}
+
+// checkAbsentConditions evaluates preconditions of updates for variables that were not found.
+// Returns PreconditionError if any precondition failed during processing.
+func (u *Updater) checkAbsentConditions() error {
+	for _, key := range u.sortedKeys(slices.Collect(maps.Keys(u.updateMap))) {
+		u.checkCondition(u.updateMap[key], false, "")
+	}
+
+	if len(u.failedConditions) == 0 {
+		return nil
+	}
+
+	keys := slices.Clone(u.failedConditions)
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Compare(u.updateOrder[a], u.updateOrder[b])
+	})
+
+	return &PreconditionError{Keys: keys}
+}
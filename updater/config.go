@@ -3,21 +3,41 @@ package updater
 import (
 	"log/slog"
 	"maps"
+
+	"github.com/4nd3r5on/go-envfile/parser"
 )
 
+// UpdateMode is a set of flags defining what ActionSet updates may do.
+// Other actions are not affected.
 type UpdateMode uint8
 
 const (
+	// ModeReplace allows changing values of existing variables.
 	ModeReplace UpdateMode = 1 << iota
+	// ModeAdd allows adding missing variables.
 	ModeAdd
+	// ModeMoveSection allows moving existing variables to the section of the update.
 	ModeMoveSection
 )
 
+// PlacementOrder defines the order of variables and sections added to the file.
+type PlacementOrder uint8
+
+const (
+	// OrderUpdates places new variables in the order of the updates slice.
+	OrderUpdates PlacementOrder = iota
+	// OrderAlphabetical places new variables and new sections sorted by name.
+	OrderAlphabetical
+)
+
 type Config struct {
 	Logger        *slog.Logger
 	Mode          UpdateMode
+	Order         PlacementOrder
 	EnsureNewLine bool
 	DefaultQuote  byte
+	// Dialect values are written for, must match the dialect of the parser
+	Dialect parser.Dialect
 
 	SectionStartComments map[string]string
 	SectionEndComments   map[string]string
@@ -26,12 +46,31 @@ type Config struct {
 var DefaultConfig = &Config{
 	Logger:               slog.Default(),
 	Mode:                 ModeReplace | ModeAdd | ModeMoveSection,
+	Order:                OrderUpdates,
 	EnsureNewLine:        true,
 	DefaultQuote:         '"',
+	Dialect:              parser.DialectDefault,
 	SectionStartComments: make(map[string]string),
 	SectionEndComments:   make(map[string]string),
 }
 
+// Clone returns a copy of the config that doesn't share section comment maps with it.
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.SectionStartComments = maps.Clone(c.SectionStartComments)
+	clone.SectionEndComments = maps.Clone(c.SectionEndComments)
+
+	if clone.SectionStartComments == nil {
+		clone.SectionStartComments = make(map[string]string)
+	}
+
+	if clone.SectionEndComments == nil {
+		clone.SectionEndComments = make(map[string]string)
+	}
+
+	return &clone
+}
+
 type Option func(*Config)
 
 func SetLogger(l *slog.Logger) Option {
@@ -42,6 +81,20 @@ func SetEnsureNewLine(v bool) Option {
 	return func(c *Config) { c.EnsureNewLine = v }
 }
 
+// SetDefaultQuote sets the quote for new values with spaces, 0 leaves them unquoted when possible.
+func SetDefaultQuote(q byte) Option {
+	return func(c *Config) { c.DefaultQuote = q }
+}
+
+// SetDialect sets the dialect values are written for.
+func SetDialect(d parser.Dialect) Option {
+	return func(c *Config) { c.Dialect = d }
+}
+
+func SetOrder(o PlacementOrder) Option {
+	return func(c *Config) { c.Order = o }
+}
+
 // As a parameter takes map of section name : comment
 // If section name is empty -- applied by default for every section.
 func SetSectionStartComments(comments map[string]string) Option {
@@ -54,6 +107,10 @@ func SetSectionEndComments(comments map[string]string) Option {
 	return func(c *Config) { maps.Copy(c.SectionEndComments, comments) }
 }
 
+func SetMode(m UpdateMode) Option {
+	return func(c *Config) { c.Mode = m }
+}
+
 func SetReplace(v bool) Option {
 	return func(c *Config) { setFlag(&c.Mode, ModeReplace, v) }
 }
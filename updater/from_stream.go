@@ -6,20 +6,24 @@ import (
 	"io"
 
 	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/parser"
 )
 
 // FromStream processes a parser stream and generates patches based on the provided updates.
-// It returns a map of patches keyed by line index to be applied to the original content.
+// It returns a map of patches keyed by line index to be applied to the original content
+// and the changes the patches make.
+// Parse failures, including a quoted value not closed till the end of the stream,
+// are returned as *parser.ParseError.
 func FromStream(
 	s common.ParserStream,
 	updates []Update,
 	options ...Option,
-) (map[int64]common.Patch, error) {
+) (map[int64]common.Patch, ChangeSet, error) {
 	var lineIdx int64
 
 	updater, err := NewUpdater(updates, options...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for {
@@ -27,15 +31,34 @@ func FromStream(
 		parsedLine, err := s.Next()
 
 		if errors.Is(err, io.EOF) {
-			return updater.HandleEOF(lineIdx)
+			patches, err := updater.HandleEOF(lineIdx)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return patches, updater.Changes(), nil
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse line %d: %w", lineIdx, err)
+			return nil, nil, lineError(err, lineIdx)
 		}
 
 		if err = updater.HandleParsedLine(lineIdx, parsedLine); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 }
+
+// lineError adds the line number to a *parser.ParseError of a parser not counting lines.
+func lineError(err error, lineIdx int64) error {
+	var parseErr *parser.ParseError
+	if !errors.As(err, &parseErr) {
+		return fmt.Errorf("failed to parse line %d: %w", lineIdx+1, err)
+	}
+
+	if parseErr.Line == 0 {
+		parseErr.Line = int(lineIdx) + 1
+	}
+
+	return parseErr
+}
@@ -0,0 +1,65 @@
+package updater
+
+import (
+	"strings"
+
+	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+// handleComment uncomments a commented out assignment if there is an ActionUncomment update for it.
+// Only single-line assignments can be uncommented.
+// The condition of the update is checked once it's known whether the variable is defined.
+func (u *Updater) handleComment(lineIdx int64, parsedLine common.ParsedLine) error {
+	uncommented, ok := UncommentLine(parsedLine.RawLine)
+	if !ok {
+		return nil
+	}
+
+	line := strings.TrimRight(uncommented, "\r\n")
+
+	data, err := parser.ParseVariable(line)
+	if err != nil || !data.Value.IsTerminated {
+		return nil // regular comment
+	}
+
+	// Must look like an assignment, not a sentence mentioning one
+	if beforeKey := strings.TrimLeft(line[:data.Key.Start], " \t"); beforeKey != "" && beforeKey != "export " {
+		return nil
+	}
+
+	update, exists := u.updateMap[data.Key.Key]
+	if !exists || update.Action != ActionUncomment {
+		return nil
+	}
+
+	// Only the first commented assignment is uncommented
+	if _, done := u.uncommented[update.Key]; done {
+		return nil
+	}
+
+	if u.EnsureNewLine && !strings.HasSuffix(uncommented, "\n") {
+		uncommented += "\n"
+	}
+
+	u.patchMap[lineIdx] = common.Patch{
+		LineIdx:      lineIdx,
+		ShouldInsert: true,
+		Insert:       uncommented,
+		RemoveLine:   true,
+	}
+	u.uncommented[update.Key] = lineIdx
+	u.trackChange(Change{
+		Key:        update.Key,
+		Kind:       ChangeUncommented,
+		OldLine:    lineIdx + 1,
+		OldSection: u.currentSection,
+		NewSection: u.currentSection,
+	}, &lineAnchor{line: lineIdx, part: anchorInsert})
+	u.sectionsLastVarLine[u.currentSection] = lineIdx
+
+	// The update stays pending: its condition depends on whether the variable is defined later in the file
+	u.Logger.Debug("uncommented variable", "key", update.Key, "line", lineIdx)
+
+	return nil
+}
@@ -1,10 +1,14 @@
 package updater
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/parser"
 )
 
 // HandleEOF processes end-of-file state and returns all accumulated patches.
@@ -12,12 +16,23 @@ import (
 func (u *Updater) HandleEOF(lineIdx int64) (map[int64]common.Patch, error) {
 	u.Logger.Debug("reached end of stream", "final_line", lineIdx)
 
+	u.eofLine = lineIdx
+
 	if u.varState != nil && !u.varState.IsTerminated {
-		return nil, fmt.Errorf(
-			"EOF with unterminated variable %s on line %d",
-			u.varState.Key,
-			u.varState.DefinitionLine,
-		)
+		return nil, u.unterminatedError()
+	}
+
+	if err := u.checkAbsentConditions(); err != nil {
+		return nil, err
+	}
+
+	// Uncommented variables weren't defined in the file, so their conditions were checked as absent
+	for key := range u.uncommented {
+		delete(u.updateMap, key)
+	}
+
+	if err := u.resolveRenameConflicts(); err != nil {
+		return nil, err
 	}
 
 	u.processNewVariables()
@@ -26,6 +41,57 @@ func (u *Updater) HandleEOF(lineIdx int64) (map[int64]common.Patch, error) {
 	return u.patchMap, nil
 }
 
+// unterminatedError reports the variable whose quoted value isn't closed till EOF.
+func (u *Updater) unterminatedError() error {
+	definition := u.varState.LinesBuf[0]
+
+	parseErr := &parser.ParseError{
+		Line:   int(u.varState.DefinitionLine) + 1,
+		Text:   definition.RawLine,
+		Kind:   parser.ErrUnterminatedQuote,
+		Detail: "value of " + u.varState.Key,
+	}
+
+	if definition.Variable != nil {
+		parseErr.Column = len(definition.Variable.Prefix) + 1
+	}
+
+	return parseErr
+}
+
+// resolveRenameConflicts checks that renamed variables don't collide with existing keys.
+// With Update.Overwrite the existing definition is removed, otherwise ErrKeyExists is returned.
+func (u *Updater) resolveRenameConflicts() error {
+	for _, oldKey := range u.sortedKeys(slices.Collect(maps.Keys(u.renamed))) {
+		update := u.renamed[oldKey]
+
+		lines, exists := u.targetLines[update.NewKey]
+		if !exists {
+			continue
+		}
+
+		if !update.Overwrite {
+			return fmt.Errorf("cannot rename %q to %q: %w", oldKey, update.NewKey, ErrKeyExists)
+		}
+
+		u.Logger.Debug("removing overwritten variable", "key", update.NewKey, "line", lines[0])
+		u.trackChange(Change{
+			Key:        update.NewKey,
+			Kind:       ChangeRemoved,
+			OldLine:    lines[0] + 1,
+			OldSection: u.targetSections[update.NewKey],
+		}, nil)
+
+		for _, line := range lines {
+			patch := u.getOrCreatePatch(line)
+			patch.RemoveLine = true
+			u.patchMap[line] = patch
+		}
+	}
+
+	return nil
+}
+
 // processNewVariables formats and stages all pending variable updates.
 func (u *Updater) processNewVariables() {
 	if len(u.updateMap) == 0 {
@@ -34,36 +100,142 @@ func (u *Updater) processNewVariables() {
 
 	u.Logger.Info("processing new variables", "count", len(u.updateMap))
 
-	for key, update := range u.updateMap {
-		formattedVar := FormatVar(update, nil, true, u.DefaultQuote)
-		u.addToSection[update.Section] += formattedVar
+	for _, key := range u.sortedKeys(slices.Collect(maps.Keys(u.updateMap))) {
+		update := u.updateMap[key]
+		if update.Action != ActionSet {
+			u.Logger.Debug("variable not found, nothing to do", "key", key, "action", update.Action)
+			u.trackChange(Change{Key: key, Kind: ChangeNotFound}, nil)
+
+			continue
+		}
+
+		if u.Mode&ModeAdd == 0 {
+			u.Logger.Debug("adding variables not allowed by update mode", "key", key)
+			u.trackChange(Change{Key: key, Kind: ChangeSkipped}, nil)
+
+			continue
+		}
+
+		formattedVar := formatVar(update, nil, true, u.DefaultQuote, &u.Dialect)
+		u.stageVariable(key, update.Section, formattedVar)
+		u.trackChange(Change{Key: key, Kind: ChangeAdded, NewSection: update.Section}, nil)
 		u.Logger.Debug("formatted new variable", "key", key, "section", update.Section)
 	}
 }
 
+// stageVariable remembers formatted variable content to be placed into a section at EOF.
+func (u *Updater) stageVariable(key, section, content string) {
+	u.addToSection[section] = append(u.addToSection[section], pendingVar{
+		Key:     key,
+		Content: content,
+		Order:   u.updateOrder[key],
+	})
+}
+
+// sortedKeys sorts keys according to the configured placement order.
+func (u *Updater) sortedKeys(keys []string) []string {
+	if u.Order == OrderAlphabetical {
+		slices.Sort(keys)
+
+		return keys
+	}
+
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Compare(u.updateOrder[a], u.updateOrder[b])
+	})
+
+	return keys
+}
+
+// sortedSections returns names of sections with staged content according to the configured placement order.
+// In update order a section goes where its first staged variable is in the updates slice.
+func (u *Updater) sortedSections() []string {
+	sections := slices.Collect(maps.Keys(u.addToSection))
+
+	if u.Order == OrderAlphabetical {
+		slices.Sort(sections)
+
+		return sections
+	}
+
+	firstOrder := func(section string) int {
+		vars := u.addToSection[section]
+		if len(vars) == 0 {
+			return 0
+		}
+
+		return slices.MinFunc(vars, func(a, b pendingVar) int { return cmp.Compare(a.Order, b.Order) }).Order
+	}
+
+	slices.SortFunc(sections, func(a, b string) int {
+		return cmp.Or(cmp.Compare(firstOrder(a), firstOrder(b)), cmp.Compare(a, b))
+	})
+
+	return sections
+}
+
+// sectionContent joins staged variables of a section according to the configured placement order.
+// Also returns the offset of every staged variable in the content.
+func (u *Updater) sectionContent(section string) (string, map[string]int) {
+	vars := u.addToSection[section]
+
+	slices.SortStableFunc(vars, func(a, b pendingVar) int {
+		if u.Order == OrderAlphabetical {
+			return cmp.Compare(a.Key, b.Key)
+		}
+
+		return cmp.Compare(a.Order, b.Order)
+	})
+
+	var sb strings.Builder
+
+	offsets := make(map[string]int, len(vars))
+	for _, v := range vars {
+		offsets[v.Key] = sb.Len()
+		sb.WriteString(v.Content)
+	}
+
+	return sb.String(), offsets
+}
+
 // distributeContentToSections inserts staged content into appropriate sections.
 func (u *Updater) distributeContentToSections(eofLine int64) {
-	var contentForNewSections string
+	var contentForNewSections strings.Builder
 
-	var contentForNewSectionsSb48 strings.Builder
+	newSectionOffsets := make(map[string]int)
 
-	for sectionName, content := range u.addToSection {
+	for _, sectionName := range u.sortedSections() {
+		content, offsets := u.sectionContent(sectionName)
 		if content == "" {
 			continue
 		}
 
 		lastVarLine, exists := u.sectionsLastVarLine[sectionName]
 		if exists {
+			base := len(u.getOrCreatePatch(lastVarLine).InsertAfter)
 			u.insertIntoExistingSection(sectionName, lastVarLine, content)
+
+			for key, offset := range offsets {
+				u.setAnchor(key, lineAnchor{line: lastVarLine, part: anchorInsertAfter, offset: base + offset})
+			}
 		} else {
-			contentForNewSectionsSb48.WriteString(u.createSection(sectionName, content))
+			section, contentOffset := u.createSection(sectionName, content)
+
+			for key, offset := range offsets {
+				newSectionOffsets[key] = contentForNewSections.Len() + contentOffset + offset
+			}
+
+			contentForNewSections.WriteString(section)
 		}
 	}
 
-	contentForNewSections += contentForNewSectionsSb48.String()
+	if contentForNewSections.Len() > 0 {
+		lineIdx := u.appendToFileEnd(contentForNewSections.String(), eofLine)
+		base := len(u.patchMap[lineIdx].InsertAfter) - contentForNewSections.Len()
 
-	if contentForNewSections != "" {
-		u.appendToFileEnd(contentForNewSections, eofLine)
+		for key, offset := range newSectionOffsets {
+			u.setAnchor(key, lineAnchor{line: lineIdx, part: anchorInsertAfter, offset: base + offset})
+		}
 	}
 }
 
@@ -80,7 +252,8 @@ func (u *Updater) insertIntoExistingSection(sectionName string, lastVarLine int6
 }
 
 // appendToFileEnd adds content to the end of the file.
-func (u *Updater) appendToFileEnd(content string, eofLine int64) {
+// Returns the index of the line the content is inserted after.
+func (u *Updater) appendToFileEnd(content string, eofLine int64) int64 {
 	// Use the last valid line index (subtract 1 to account for EOF marker)
 	lineIdx := max(0, eofLine-1)
 
@@ -92,6 +265,8 @@ func (u *Updater) appendToFileEnd(content string, eofLine int64) {
 	patch.ShouldInsertAfter = true
 	patch.InsertAfter += content
 	u.patchMap[lineIdx] = patch
+
+	return lineIdx
 }
 
 // getOrCreatePatch retrieves an existing patch or creates a new one.
@@ -104,7 +279,13 @@ func (u *Updater) getOrCreatePatch(lineIdx int64) common.Patch {
 }
 
 // createSection builds a complete section with comments and content.
-func (u *Updater) createSection(name, content string) string {
+// Also returns the offset of the content in the section.
+// Variables without a section are returned as is.
+func (u *Updater) createSection(name, content string) (string, int) {
+	if name == "" {
+		return content, 0
+	}
+
 	startComment := getSectionComment(name, u.SectionStartComments)
 	endComment := getSectionComment(name, u.SectionEndComments)
 
@@ -115,12 +296,15 @@ func (u *Updater) createSection(name, content string) string {
 
 	builder.WriteString(sectionStart)
 	builder.WriteByte('\n')
+
+	contentOffset := builder.Len()
+
 	builder.WriteString(content)
 	builder.WriteByte('\n')
 	builder.WriteString(sectionEnd)
 	builder.WriteByte('\n')
 
-	return builder.String()
+	return builder.String(), contentOffset
 }
 
 // getSectionComment retrieves the comment for a section, falling back to default.
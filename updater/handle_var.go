@@ -48,6 +48,27 @@ func (u *Updater) patchVar() error {
 		return nil // not terminated yet
 	}
 
+	// The variable is defined after all, undo uncommenting
+	if line, ok := u.uncommented[u.varState.Key]; ok {
+		u.Logger.Debug("variable already defined, keeping it commented",
+			"key", u.varState.Key,
+			"commented_line", line,
+			"line", u.varState.DefinitionLine)
+		delete(u.patchMap, line)
+		delete(u.uncommented, u.varState.Key)
+	}
+
+	// Remember where rename targets are defined to check for conflicts at EOF
+	if _, isTarget := u.renameTargets[u.varState.Key]; isTarget {
+		if _, seen := u.targetLines[u.varState.Key]; !seen {
+			u.targetSections[u.varState.Key] = sectionOf(u.varState.LinesBuf[0])
+		}
+
+		for i := range u.varState.LinesBuf {
+			u.targetLines[u.varState.Key] = append(u.targetLines[u.varState.Key], u.varState.DefinitionLine+int64(i))
+		}
+	}
+
 	varUpdate, shouldUpdate := u.updateMap[u.varState.Key]
 	if !shouldUpdate {
 		u.Logger.Debug("skipping variable (no update)", "key", u.varState.Key, "line", u.varState.DefinitionLine)
@@ -57,23 +78,53 @@ func (u *Updater) patchVar() error {
 		return nil
 	}
 
-	updateBlock := processVarUpdate(
-		u.varState.DefinitionLine,
-		varUpdate,
-		u.varState.LinesBuf,
-		u.EnsureNewLine,
-		u.DefaultQuote,
-		u.Logger,
-	)
+	if !u.checkCondition(varUpdate, true, reconstructMultiLineValue(u.varState.LinesBuf)) {
+		u.varState = nil
+
+		return nil
+	}
+
+	var updateBlock UpdateBlock
+
+	switch varUpdate.Action {
+	case ActionDelete, ActionComment:
+		updateBlock = processVarRemoval(
+			u.varState.DefinitionLine,
+			varUpdate,
+			u.varState.LinesBuf,
+			u.EnsureNewLine,
+			u.Logger,
+		)
+	case ActionRename:
+		updateBlock = processVarRename(
+			u.varState.DefinitionLine,
+			varUpdate,
+			u.varState.LinesBuf,
+			u.EnsureNewLine,
+			u.Logger,
+		)
+		u.renamed[varUpdate.Key] = varUpdate
+	case ActionUncomment:
+		u.Logger.Debug("variable already uncommented", "key", varUpdate.Key, "line", u.varState.DefinitionLine)
+	default:
+		updateBlock = processVarUpdate(
+			u.varState.DefinitionLine,
+			varUpdate,
+			u.varState.LinesBuf,
+			u.Config,
+		)
+	}
 
 	// Apply patches
 	for _, patch := range updateBlock.Patches {
 		u.patchMap[patch.LineIdx] = patch
 	}
 
+	u.trackBlock(varUpdate, updateBlock)
+
 	// Track content to add to section
 	if updateBlock.AddVariable != nil && updateBlock.AddVariable.Content != "" {
-		u.addToSection[updateBlock.AddVariable.Section] += updateBlock.AddVariable.Content
+		u.stageVariable(varUpdate.Key, updateBlock.AddVariable.Section, updateBlock.AddVariable.Content)
 	}
 
 	// Mark update as processed
@@ -84,3 +135,36 @@ func (u *Updater) patchVar() error {
 
 	return nil
 }
+
+// trackBlock records the change made to the current variable by an update block.
+// Position of a moved variable is set when it's placed into a section at EOF.
+func (u *Updater) trackBlock(update Update, block UpdateBlock) {
+	line := u.varState.DefinitionLine
+	section := sectionOf(u.varState.LinesBuf[0])
+
+	change := Change{
+		Key:        update.Key,
+		Kind:       block.Kind,
+		OldLine:    line + 1,
+		OldSection: section,
+		NewSection: section,
+	}
+
+	var anchor *lineAnchor
+
+	switch block.Kind {
+	case ChangeUnchanged, ChangeSkipped:
+		anchor = &lineAnchor{line: line, part: anchorLine}
+	case ChangeUpdated, ChangeCommented:
+		anchor = &lineAnchor{line: line, part: anchorInsert}
+	case ChangeRenamed:
+		change.NewKey = update.NewKey
+		anchor = &lineAnchor{line: line, part: anchorInsert}
+	case ChangeMoved, ChangeAdded:
+		change.NewSection = update.Section
+	case ChangeRemoved:
+		change.NewSection = ""
+	}
+
+	u.trackChange(change, anchor)
+}
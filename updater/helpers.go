@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/parser"
 )
 
 type AddVariable struct {
@@ -19,20 +20,37 @@ type UpdateBlock struct {
 	Patches []common.Patch
 	// If variable needs to be added in a different section
 	AddVariable *AddVariable
+	// What happens to the variable
+	Kind ChangeKind
 }
 
 // FormatVar creates a formatted variable line from an update and optional original data.
 // Takes value from the update while preserving formatting from the original (like comment and prefix if exists).
+// The value is written so parser.DialectDefault reads it back unchanged.
 func FormatVar(update Update, orig *common.VariableData, ensureNewLine bool, defaultQuote byte) string {
+	return formatVar(update, orig, ensureNewLine, defaultQuote, &parser.DialectDefault)
+}
+
+// formatVar implements FormatVar for a dialect.
+func formatVar(
+	update Update,
+	orig *common.VariableData,
+	ensureNewLine bool,
+	defaultQuote byte,
+	dialect *parser.Dialect,
+) string {
 	var (
 		prefix string
 		suffix string
-		value  string
 	)
 
 	if orig != nil {
 		prefix = orig.Prefix
 		suffix = orig.Suffix
+
+		if orig.IsBare {
+			prefix += "="
+		}
 	} else {
 		if update.Prefix != "" {
 			prefix = update.Prefix + update.Key + "="
@@ -51,24 +69,7 @@ func FormatVar(update Update, orig *common.VariableData, ensureNewLine bool, def
 		quote = defaultQuote
 	}
 
-	value = update.Value
-
-	// Escape quotes if quoting is used
-	if quote != 0 {
-		escaped := make([]byte, 0, len(value))
-
-		for i := range len(value) {
-			switch value[i] {
-			case quote, '\\':
-				escaped = append(escaped, '\\')
-			}
-
-			escaped = append(escaped, value[i])
-		}
-
-		value = string(escaped)
-		value = string(quote) + value + string(quote)
-	}
+	value := encodeValue(update.Value, dialect, quote, defaultQuote)
 
 	// Add inline comment if suffix is empty/whitespace and comment is provided
 	if common.IsEmptyStr(suffix) && update.InlineComment != "" {
@@ -82,6 +83,20 @@ func FormatVar(update Update, orig *common.VariableData, ensureNewLine bool, def
 	return prefix + value + suffix
 }
 
+// encodeValue writes a value with the first of the quotes the dialect reads it back correctly with.
+// Falls back to double quotes of parser.DialectDefault if no quote fits.
+func encodeValue(value string, dialect *parser.Dialect, quotes ...byte) string {
+	for _, quote := range append(quotes, '"', '\'', '`') {
+		if encoded, ok := dialect.Encode(value, quote); ok {
+			return encoded
+		}
+	}
+
+	encoded, _ := parser.DialectDefault.Encode(value, '"')
+
+	return encoded
+}
+
 // reconstructMultiLineValue reconstructs the complete value from multiple parsed lines.
 // origLines contains the definition line and all continuation lines.
 func reconstructMultiLineValue(origLines []common.ParsedLine) string {
@@ -107,18 +122,34 @@ func reconstructMultiLineValue(origLines []common.ParsedLine) string {
 	return strings.Join(valueParts, "\n")
 }
 
+// joinRawLines returns the raw lines of a variable as a single string.
+func joinRawLines(origLines []common.ParsedLine, ensureNewLine bool) string {
+	var sb strings.Builder
+	for _, line := range origLines {
+		sb.WriteString(line.RawLine)
+	}
+
+	content := sb.String()
+	if ensureNewLine && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return content
+}
+
 // processVarUpdate creates an update block for updating a variable.
 // origLines must contain at least one line (the variable definition line).
 // First line defines the variable, subsequent lines are value continuation parts.
 // Returns an UpdateBlock containing all necessary patches and optional move information.
+// Changes not allowed by cfg.Mode are skipped.
 func processVarUpdate(
 	lineIdx int64,
 	update Update,
 	origLines []common.ParsedLine,
-	ensureNewLine bool,
-	defaultQuote byte,
-	logger *slog.Logger,
+	cfg *Config,
 ) UpdateBlock {
+	logger, ensureNewLine := cfg.Logger, cfg.EnsureNewLine
+
 	if len(origLines) == 0 {
 		logger.Error("processVarUpdate called with empty origLines", "key", update.Key)
 
@@ -126,8 +157,9 @@ func processVarUpdate(
 			Patches: []common.Patch{},
 			AddVariable: &AddVariable{
 				Section: update.Section,
-				Content: FormatVar(update, nil, ensureNewLine, defaultQuote),
+				Content: formatVar(update, nil, ensureNewLine, cfg.DefaultQuote, &cfg.Dialect),
 			},
+			Kind: ChangeAdded,
 		}
 	}
 
@@ -139,8 +171,9 @@ func processVarUpdate(
 			Patches: []common.Patch{},
 			AddVariable: &AddVariable{
 				Section: update.Section,
-				Content: FormatVar(update, nil, ensureNewLine, defaultQuote),
+				Content: formatVar(update, nil, ensureNewLine, cfg.DefaultQuote, &cfg.Dialect),
 			},
+			Kind: ChangeAdded,
 		}
 	}
 
@@ -153,8 +186,8 @@ func processVarUpdate(
 		currentSection = definitionLine.SectionData.Name
 	}
 
-	// Check if value matches
-	valCorrect := originalValue == update.Value
+	// Check if value matches, a bare key has no value to match
+	valCorrect := originalValue == update.Value && !definitionLine.Variable.IsBare
 
 	// Check if section matches
 	sectionCorrect := currentSection == update.Section
@@ -164,6 +197,18 @@ func processVarUpdate(
 		sectionCorrect = true
 	}
 
+	// Keep what the mode doesn't allow to change
+	skipValue := !valCorrect && cfg.Mode&ModeReplace == 0
+	if skipValue {
+		update.Value = originalValue
+		valCorrect = true
+	}
+
+	skipSection := !sectionCorrect && cfg.Mode&ModeMoveSection == 0
+	if skipSection {
+		sectionCorrect = true
+	}
+
 	logger.Debug("variable update analysis",
 		"key", update.Key,
 		"line", lineIdx,
@@ -172,14 +217,26 @@ func processVarUpdate(
 		"current_section", currentSection,
 		"target_section", update.Section,
 		"multiline", len(origLines) > 1,
+		"skip_value", skipValue,
+		"skip_section", skipSection,
 	)
 
 	// Case 1: Both value and section are correct - no changes needed
 	if valCorrect && sectionCorrect {
+		if skipValue || skipSection {
+			logger.Debug("changes not allowed by update mode", "key", update.Key)
+
+			return UpdateBlock{
+				Patches: []common.Patch{},
+				Kind:    ChangeSkipped,
+			}
+		}
+
 		logger.Debug("no changes needed for variable", "key", update.Key)
 
 		return UpdateBlock{
 			Patches: []common.Patch{},
+			Kind:    ChangeUnchanged,
 		}
 	}
 
@@ -192,18 +249,16 @@ func processVarUpdate(
 		}
 	}
 
-	// Format the new variable content
-	varContent := FormatVar(update, definitionLine.Variable, ensureNewLine, defaultQuote)
-
 	// Case 2: Value needs updating, but section is correct - update in place
 	if !valCorrect && sectionCorrect {
 		logger.Debug("updating variable in place", "key", update.Key, "line", lineIdx)
 		// Insert new content before removing the first line
-		patches[0].Insert = varContent
+		patches[0].Insert = formatVar(update, definitionLine.Variable, ensureNewLine, cfg.DefaultQuote, &cfg.Dialect)
 		patches[0].ShouldInsert = true
 
 		return UpdateBlock{
 			Patches: patches,
+			Kind:    ChangeUpdated,
 		}
 	}
 
@@ -214,6 +269,14 @@ func processVarUpdate(
 		"to_section", update.Section,
 	)
 
+	// A value that isn't replaced is moved as written
+	var varContent string
+	if valCorrect {
+		varContent = joinRawLines(origLines, ensureNewLine)
+	} else {
+		varContent = formatVar(update, definitionLine.Variable, ensureNewLine, cfg.DefaultQuote, &cfg.Dialect)
+	}
+
 	// Remove all lines from current location and mark for insertion in new section
 	return UpdateBlock{
 		Patches: patches,
@@ -221,5 +284,120 @@ func processVarUpdate(
 			Section: update.Section,
 			Content: varContent,
 		},
+		Kind: ChangeMoved,
+	}
+}
+
+// processVarRemoval creates an update block for deleting or commenting out a variable.
+// origLines contains the definition line and all continuation lines.
+// With ActionComment every line is kept, prefixed with "# ".
+func processVarRemoval(
+	lineIdx int64,
+	update Update,
+	origLines []common.ParsedLine,
+	ensureNewLine bool,
+	logger *slog.Logger,
+) UpdateBlock {
+	patches := make([]common.Patch, len(origLines))
+	for i, line := range origLines {
+		patches[i] = common.Patch{
+			LineIdx:    lineIdx + int64(i),
+			RemoveLine: true,
+		}
+
+		if update.Action == ActionComment {
+			patches[i].Insert = CommentLine(line.RawLine, ensureNewLine)
+			patches[i].ShouldInsert = true
+		}
+	}
+
+	if update.Action == ActionComment {
+		logger.Debug("commenting out variable", "key", update.Key, "line", lineIdx, "lines", len(origLines))
+
+		return UpdateBlock{
+			Patches: patches,
+			Kind:    ChangeCommented,
+		}
+	}
+
+	logger.Debug("deleting variable", "key", update.Key, "line", lineIdx, "lines", len(origLines))
+
+	return UpdateBlock{
+		Patches: patches,
+		Kind:    ChangeRemoved,
+	}
+}
+
+// processVarRename creates an update block for renaming a variable.
+// Only the key in the definition line is rewritten,
+// the rest of the line and continuation lines are kept as is.
+func processVarRename(
+	lineIdx int64,
+	update Update,
+	origLines []common.ParsedLine,
+	ensureNewLine bool,
+	logger *slog.Logger,
+) UpdateBlock {
+	raw := origLines[0].RawLine
+
+	var (
+		key parser.KeyData
+		err error
+	)
+
+	if equalIdx := strings.IndexByte(raw, '='); equalIdx >= 0 {
+		key, err = parser.ExtractKey(raw, equalIdx)
+	} else {
+		key, err = parser.ParseBareKey(raw)
+	}
+
+	if err != nil {
+		logger.Error("failed to locate key", "key", update.Key, "line", lineIdx, "error", err)
+
+		return UpdateBlock{}
+	}
+
+	renamed := raw[:key.Start] + update.NewKey + raw[key.End+1:]
+	if ensureNewLine && !strings.HasSuffix(renamed, "\n") {
+		renamed += "\n"
+	}
+
+	logger.Debug("renaming variable", "key", update.Key, "new_key", update.NewKey, "line", lineIdx)
+
+	return UpdateBlock{
+		Patches: []common.Patch{{
+			LineIdx:      lineIdx,
+			ShouldInsert: true,
+			Insert:       renamed,
+			RemoveLine:   true,
+		}},
+		Kind: ChangeRenamed,
 	}
 }
+
+// CommentLine comments a raw line out with "# ".
+func CommentLine(raw string, ensureNewLine bool) string {
+	commented := "# " + raw
+	if ensureNewLine && !strings.HasSuffix(commented, "\n") {
+		commented += "\n"
+	}
+
+	return commented
+}
+
+// UncommentLine removes the comment mark (and spaces after it) from a commented line,
+// keeping the indentation before it.
+// Returns false if the line is not a comment.
+func UncommentLine(raw string) (string, bool) {
+	hashIdx := common.SkipSpaces(raw, 0)
+	if hashIdx >= len(raw) || raw[hashIdx] != '#' {
+		return "", false
+	}
+
+	textStart := hashIdx + 1
+	for textStart < len(raw) && (raw[textStart] == ' ' || raw[textStart] == '\t') {
+		textStart++
+	}
+
+	return raw[:hashIdx] + raw[textStart:], true
+}
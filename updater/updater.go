@@ -1,25 +1,79 @@
 package updater
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/4nd3r5on/go-envfile/common"
 )
 
+// Action defines what an Update does with a variable.
+type Action uint8
+
+const (
+	// ActionSet sets the value, adding the variable if it doesn't exist.
+	ActionSet Action = iota
+	// ActionDelete removes the variable including its multiline continuation lines.
+	ActionDelete
+	// ActionComment comments the variable out with "# " keeping its formatting.
+	ActionComment
+	// ActionUncomment uncomments the first commented out assignment of the variable.
+	// Does nothing if the variable is already defined.
+	ActionUncomment
+	// ActionRename changes the key to NewKey keeping the value and formatting.
+	ActionRename
+)
+
+// Condition is a precondition checked against the current variable before applying an Update.
+type Condition uint8
+
+const (
+	// CondAlways applies the update unconditionally.
+	CondAlways Condition = iota
+	// CondAbsent applies the update only if the variable is not defined.
+	CondAbsent
+	// CondPresent applies the update only if the variable is defined.
+	CondPresent
+	// CondEquals applies the update only if the current value equals Update.IfValue.
+	CondEquals
+)
+
+// ErrKeyExists is returned when a variable is renamed to a key that is already defined.
+var ErrKeyExists = errors.New("key already exists")
+
 type Update struct {
 	Key     string
 	Value   string
 	Section string // empty string for no section
+	Action  Action // ActionSet by default
 
 	// If variable already exists -- won't move section for this specific variable
 	IgnoreSection bool
 
+	// Precondition, CondAlways by default.
+	// If it fails for any update, FromStream returns PreconditionError and no patches.
+	If Condition
+	// Expected current value for CondEquals
+	IfValue string
+
+	// Target key for ActionRename
+	NewKey string
+	// For ActionRename: remove the existing NewKey definition instead of failing
+	Overwrite bool
+
 	Prefix string // for "export " before key for example
 	// Works only for adding variables
 	// If variable existed before -- keeping existing suffix
 	InlineComment string
 }
 
+// pendingVar is a formatted variable waiting to be placed into a section at EOF.
+type pendingVar struct {
+	Key     string
+	Content string
+	Order   int // index of the update in the updates slice
+}
+
 type VariableState struct {
 	DefinitionLine int64
 	Key            string
@@ -31,40 +85,79 @@ type Updater struct {
 	*Config
 
 	// input
-	updateMap map[string]Update
+	updateMap   map[string]Update
+	updateOrder map[string]int // key : index in the updates slice
 	// updater state
 	currentSection      string
-	sectionsLastVarLine map[string]int64  // for locating where to place a patch for a section
-	addToSection        map[string]string // for something that we need to move into another section
+	sectionsLastVarLine map[string]int64        // for locating where to place a patch for a section
+	addToSection        map[string][]pendingVar // for something that we need to move into another section
 	varState            *VariableState
+	uncommented         map[string]int64   // key : line uncommented with ActionUncomment
+	renameTargets       map[string]string  // new key : old key for ActionRename
+	renamed             map[string]Update  // old key : applied rename update
+	targetLines         map[string][]int64 // new key : lines of its existing definition
+	targetSections      map[string]string  // new key : section of its existing definition
+	failedConditions    []string           // keys of updates whose precondition failed
+	eofLine             int64
 	// output
 	patchMap map[int64]common.Patch
+	changes  map[string]*trackedChange
 }
 
+// NewUpdater creates an updater with a copy of DefaultConfig modified by options.
 func NewUpdater(updates []Update, options ...Option) (*Updater, error) {
-	cfg := DefaultConfig
+	cfg := DefaultConfig.Clone()
 	for _, option := range options {
 		option(cfg)
 	}
 
 	updateMap := make(map[string]Update, len(updates))
-	for _, update := range updates {
+	updateOrder := make(map[string]int, len(updates))
+	renameTargets := make(map[string]string)
+
+	for i, update := range updates {
 		if _, exists := updateMap[update.Key]; exists {
 			return nil, fmt.Errorf("duplicate update for key %q: each key must appear only once in updates", update.Key)
 		}
 
+		if update.Action == ActionRename {
+			if update.NewKey == "" {
+				return nil, fmt.Errorf("rename of key %q: new key is empty", update.Key)
+			}
+
+			if prev, exists := renameTargets[update.NewKey]; exists {
+				return nil, fmt.Errorf("keys %q and %q are both renamed to %q", prev, update.Key, update.NewKey)
+			}
+
+			renameTargets[update.NewKey] = update.Key
+		}
+
 		updateMap[update.Key] = update
+		updateOrder[update.Key] = i
 		cfg.Logger.Debug("registered update", "key", update.Key, "section", update.Section)
 	}
 
+	for newKey, oldKey := range renameTargets {
+		if _, exists := updateMap[newKey]; exists {
+			return nil, fmt.Errorf("key %q is renamed to %q, which has its own update", oldKey, newKey)
+		}
+	}
+
 	cfg.Logger.Info("starting stream processing", "total_updates", len(updates))
 
 	return &Updater{
 		Config:              cfg,
 		updateMap:           updateMap,
+		updateOrder:         updateOrder,
 		sectionsLastVarLine: make(map[string]int64),
-		addToSection:        make(map[string]string),
+		addToSection:        make(map[string][]pendingVar),
+		uncommented:         make(map[string]int64),
+		renameTargets:       renameTargets,
+		renamed:             make(map[string]Update),
+		targetLines:         make(map[string][]int64),
+		targetSections:      make(map[string]string),
 		patchMap:            make(map[int64]common.Patch),
+		changes:             make(map[string]*trackedChange),
 	}, nil
 }
 
@@ -78,6 +171,8 @@ func (u *Updater) HandleParsedLine(lineIdx int64, parsedLine common.ParsedLine)
 		return u.handleVar(lineIdx, parsedLine)
 	case common.LineTypeVal:
 		return u.handleValPart(lineIdx, parsedLine)
+	case common.LineTypeComment:
+		return u.handleComment(lineIdx, parsedLine)
 	default:
 		return nil
 	}
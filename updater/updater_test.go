@@ -0,0 +1,653 @@
+package updater_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/parser"
+	"github.com/4nd3r5on/go-envfile/updater"
+)
+
+// applyUpdates runs updates against content and returns the patched content.
+func applyUpdates(t *testing.T, content string, updates []updater.Update, options ...updater.Option) (string, error) {
+	t.Helper()
+
+	out, _, err := runUpdates(t, content, updates, options...)
+
+	return out, err
+}
+
+// runUpdates runs updates against content and returns the patched content and the changes.
+func runUpdates(
+	t *testing.T,
+	content string,
+	updates []updater.Update,
+	options ...updater.Option,
+) (string, updater.ChangeSet, error) {
+	t.Helper()
+
+	return runUpdatesWithParser(t, content, nil, updates, options...)
+}
+
+// runUpdatesWithParser works like runUpdates parsing content with parser options.
+func runUpdatesWithParser(
+	t *testing.T,
+	content string,
+	parserOptions []parser.Option,
+	updates []updater.Update,
+	options ...updater.Option,
+) (string, updater.ChangeSet, error) {
+	t.Helper()
+
+	logger := slog.New(slog.DiscardHandler)
+	options = append([]updater.Option{updater.SetLogger(logger)}, options...)
+	parserOptions = append([]parser.Option{parser.SetLogger(logger)}, parserOptions...)
+
+	p := parser.NewFileParser(nil, bufio.NewReader(strings.NewReader(content)), false, parserOptions...)
+
+	patches, changes, err := updater.FromStream(p, updates, options...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	spans, err := common.ScanLineOffsetsReader(bufio.NewReader(strings.NewReader(content)), patches, logger)
+	if err != nil {
+		t.Fatalf("ScanLineOffsetsReader() failed: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	err = common.ProcessPatches(strings.NewReader(content), int64(len(content)), &out, spans, patches, logger)
+	if err != nil {
+		t.Fatalf("ProcessPatches() failed: %v", err)
+	}
+
+	return out.String(), changes, nil
+}
+
+func TestPlacementOrder(t *testing.T) {
+	updates := []updater.Update{
+		{Key: "ZETA", Value: "1"},
+		{Key: "DB_PORT", Value: "5432", Section: "db"},
+		{Key: "ALPHA", Value: "2"},
+		{Key: "API_HOST", Value: "api", Section: "api"},
+		{Key: "DB_HOST", Value: "localhost", Section: "db"},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		options []updater.Option
+		want    string
+	}{
+		{
+			name:    "updates order",
+			content: "EXISTING=1\n",
+			options: []updater.Option{updater.SetOrder(updater.OrderUpdates)},
+			want: "EXISTING=1\nZETA=1\nALPHA=2\n" +
+				"# [SECTION: db]\nDB_PORT=5432\nDB_HOST=localhost\n\n# [SECTION_END: db]\n" +
+				"# [SECTION: api]\nAPI_HOST=api\n\n# [SECTION_END: api]\n",
+		},
+		{
+			name:    "alphabetical order",
+			content: "EXISTING=1\n",
+			options: []updater.Option{updater.SetOrder(updater.OrderAlphabetical)},
+			want: "EXISTING=1\nALPHA=2\nZETA=1\n" +
+				"# [SECTION: api]\nAPI_HOST=api\n\n# [SECTION_END: api]\n" +
+				"# [SECTION: db]\nDB_HOST=localhost\nDB_PORT=5432\n\n# [SECTION_END: db]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run several times, map iteration order must not leak into the output
+			for range 10 {
+				got, err := applyUpdates(t, tt.content, updates, tt.options...)
+				if err != nil {
+					t.Fatalf("applyUpdates() failed: %v", err)
+				}
+
+				if got != tt.want {
+					t.Fatalf("applyUpdates() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		updates []updater.Update
+		want    string
+	}{
+		{
+			name:    "delete variable",
+			content: "A=1\nB=2\nC=3\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionDelete}},
+			want:    "A=1\nC=3\n",
+		},
+		{
+			name:    "delete multiline variable",
+			content: "A=1\nB=\"line1\nline2\nline3\"\nC=3\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionDelete}},
+			want:    "A=1\nC=3\n",
+		},
+		{
+			name:    "delete missing variable",
+			content: "A=1\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionDelete}},
+			want:    "A=1\n",
+		},
+		{
+			name:    "comment out variable",
+			content: "A=1\nexport B='x' # note\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionComment}},
+			want:    "A=1\n# export B='x' # note\n",
+		},
+		{
+			name:    "comment out multiline variable",
+			content: "B=\"line1\nline2\"\nC=3\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionComment}},
+			want:    "# B=\"line1\n# line2\"\nC=3\n",
+		},
+		{
+			name:    "uncomment variable",
+			content: "A=1\n# export B='x' # note\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionUncomment}},
+			want:    "A=1\nexport B='x' # note\n",
+		},
+		{
+			name:    "uncomment ignores prose",
+			content: "# set B=1 to enable\n#B=2\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionUncomment}},
+			want:    "# set B=1 to enable\nB=2\n",
+		},
+		{
+			name:    "uncomment keeps existing definition",
+			content: "# B=1\nB=2\n",
+			updates: []updater.Update{{Key: "B", Action: updater.ActionUncomment}},
+			want:    "# B=1\nB=2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyUpdates(t, tt.content, tt.updates)
+			if err != nil {
+				t.Fatalf("applyUpdates() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("applyUpdates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		updates []updater.Update
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "rename keeps formatting",
+			content: "export  REDIS_URL = \"redis://x\" # cache\n",
+			updates: []updater.Update{{Key: "REDIS_URL", NewKey: "CACHE_URL", Action: updater.ActionRename}},
+			want:    "export  CACHE_URL = \"redis://x\" # cache\n",
+		},
+		{
+			name:    "rename multiline variable",
+			content: "CERT=\"line1\nline2\"\nA=1\n",
+			updates: []updater.Update{{Key: "CERT", NewKey: "TLS_CERT", Action: updater.ActionRename}},
+			want:    "TLS_CERT=\"line1\nline2\"\nA=1\n",
+		},
+		{
+			name:    "rename missing variable",
+			content: "A=1\n",
+			updates: []updater.Update{{Key: "B", NewKey: "C", Action: updater.ActionRename}},
+			want:    "A=1\n",
+		},
+		{
+			name:    "target exists",
+			content: "REDIS_URL=a\nCACHE_URL=b\n",
+			updates: []updater.Update{{Key: "REDIS_URL", NewKey: "CACHE_URL", Action: updater.ActionRename}},
+			wantErr: updater.ErrKeyExists,
+		},
+		{
+			name:    "target exists with overwrite",
+			content: "CACHE_URL=\"b\nc\"\nREDIS_URL=a\n",
+			updates: []updater.Update{{Key: "REDIS_URL", NewKey: "CACHE_URL", Action: updater.ActionRename, Overwrite: true}},
+			want:    "CACHE_URL=a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyUpdates(t, tt.content, tt.updates)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("applyUpdates() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("applyUpdates() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("applyUpdates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		updates    []updater.Update
+		want       string
+		wantFailed []string
+	}{
+		{
+			name:    "absent condition on missing key",
+			content: "A=1\n",
+			updates: []updater.Update{{Key: "B", Value: "2", If: updater.CondAbsent}},
+			want:    "A=1\nB=2\n",
+		},
+		{
+			name:       "absent condition on existing key",
+			content:    "A=1\n",
+			updates:    []updater.Update{{Key: "A", Value: "2", If: updater.CondAbsent}},
+			wantFailed: []string{"A"},
+		},
+		{
+			name:    "present condition",
+			content: "A=1\n",
+			updates: []updater.Update{{Key: "A", Value: "2", If: updater.CondPresent}},
+			want:    "A=2\n",
+		},
+		{
+			name:    "equals condition on multiline value",
+			content: "A=\"x\ny\"\n",
+			updates: []updater.Update{{Key: "A", Value: "z", If: updater.CondEquals, IfValue: "x\ny"}},
+			want:    "A=\"z\"\n",
+		},
+		{
+			name:    "all failed keys are listed and nothing is applied",
+			content: "A=1\nB=2\n",
+			updates: []updater.Update{
+				{Key: "C", Value: "3", If: updater.CondPresent},
+				{Key: "B", Value: "3"},
+				{Key: "A", Value: "3", If: updater.CondEquals, IfValue: "manual"},
+			},
+			wantFailed: []string{"C", "A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyUpdates(t, tt.content, tt.updates)
+			if tt.wantFailed != nil {
+				var condErr *updater.PreconditionError
+				if !errors.As(err, &condErr) {
+					t.Fatalf("applyUpdates() error = %v, want PreconditionError", err)
+				}
+
+				if !slices.Equal(condErr.Keys, tt.wantFailed) {
+					t.Errorf("PreconditionError.Keys = %v, want %v", condErr.Keys, tt.wantFailed)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("applyUpdates() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("applyUpdates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		updates []updater.Update
+		want    string
+		changes updater.ChangeSet
+	}{
+		{
+			name:    "set updates",
+			content: "A=1\nB=old\nM=m\n# [SECTION: db]\nC=3\n# [SECTION_END: db]\n",
+			updates: []updater.Update{
+				{Key: "A", Value: "1"},
+				{Key: "B", Action: updater.ActionDelete},
+				{Key: "C", Value: "4", Section: "db"},
+				{Key: "M", Value: "m", Section: "db"},
+				{Key: "D", Value: "5", Section: "db"},
+				{Key: "E", Value: "6", Section: "api"},
+				{Key: "X", Action: updater.ActionDelete},
+			},
+			want: "A=1\n# [SECTION: db]\nC=4\nM=m\nD=5\n# [SECTION_END: db]\n" +
+				"# [SECTION: api]\nE=6\n\n# [SECTION_END: api]\n",
+			changes: updater.ChangeSet{
+				{Key: "A", Kind: updater.ChangeUnchanged, OldLine: 1, NewLine: 1},
+				{Key: "B", Kind: updater.ChangeRemoved, OldLine: 2},
+				{Key: "C", Kind: updater.ChangeUpdated, OldLine: 5, NewLine: 3, OldSection: "db", NewSection: "db"},
+				{Key: "M", Kind: updater.ChangeMoved, OldLine: 3, NewLine: 4, NewSection: "db"},
+				{Key: "D", Kind: updater.ChangeAdded, NewLine: 5, NewSection: "db"},
+				{Key: "E", Kind: updater.ChangeAdded, NewLine: 8, NewSection: "api"},
+				{Key: "X", Kind: updater.ChangeNotFound},
+			},
+		},
+		{
+			name:    "rename, comment and uncomment",
+			content: "OLD=1\nNEW=2\n# U=3\nK=4\n",
+			updates: []updater.Update{
+				{Key: "OLD", Action: updater.ActionRename, NewKey: "NEW", Overwrite: true},
+				{Key: "U", Action: updater.ActionUncomment},
+				{Key: "K", Action: updater.ActionComment},
+			},
+			want: "NEW=1\nU=3\n# K=4\n",
+			changes: updater.ChangeSet{
+				{Key: "OLD", Kind: updater.ChangeRenamed, NewKey: "NEW", OldLine: 1, NewLine: 1},
+				{Key: "NEW", Kind: updater.ChangeRemoved, OldLine: 2},
+				{Key: "U", Kind: updater.ChangeUncommented, OldLine: 3, NewLine: 2},
+				{Key: "K", Kind: updater.ChangeCommented, OldLine: 4, NewLine: 3},
+			},
+		},
+		{
+			name:    "uncomment of defined variable",
+			content: "# U=1\nU=2\n",
+			updates: []updater.Update{{Key: "U", Action: updater.ActionUncomment}},
+			want:    "# U=1\nU=2\n",
+			changes: updater.ChangeSet{
+				{Key: "U", Kind: updater.ChangeUnchanged, OldLine: 2, NewLine: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changes, err := runUpdates(t, tt.content, tt.updates, updater.SetOrder(updater.OrderUpdates))
+			if err != nil {
+				t.Fatalf("runUpdates() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("runUpdates() = %q, want %q", got, tt.want)
+			}
+
+			if !slices.Equal(changes, tt.changes) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.changes)
+			}
+		})
+	}
+}
+
+func TestConfigIsolation(t *testing.T) {
+	const content = "A=1\n"
+
+	updates := []updater.Update{{Key: "B", Value: "2", Section: "db"}}
+	want := "A=1\n# [SECTION: db]\nB=2\n\n# [SECTION_END: db]\n"
+
+	// Options of one updater must not leak into later ones or into DefaultConfig
+	_, err := applyUpdates(t, content, updates,
+		updater.SetSectionStartComments(map[string]string{"db": "Database"}),
+		updater.SetEnsureNewLine(false),
+	)
+	if err != nil {
+		t.Fatalf("applyUpdates() failed: %v", err)
+	}
+
+	if len(updater.DefaultConfig.SectionStartComments) != 0 || !updater.DefaultConfig.EnsureNewLine {
+		t.Errorf("DefaultConfig modified by options: %+v", updater.DefaultConfig)
+	}
+
+	// Concurrent use with different options
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Go(func() {
+			options := []updater.Option{updater.SetOrder(updater.PlacementOrder(i % 2))}
+			if i%2 == 0 {
+				options = append(options, updater.SetSectionStartComments(map[string]string{"other": "x"}))
+			}
+
+			got, err := applyUpdates(t, content, updates, options...)
+			if err != nil {
+				t.Errorf("applyUpdates() failed: %v", err)
+
+				return
+			}
+
+			if got != want {
+				t.Errorf("applyUpdates() = %q, want %q", got, want)
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+func TestModes(t *testing.T) {
+	const content = "A=1\n# [SECTION: db]\nB=2\n# [SECTION_END: db]\n"
+
+	updates := []updater.Update{
+		{Key: "A", Value: "10", Section: "db"},
+		{Key: "B", Value: "20", Section: "db"},
+		{Key: "C", Value: "30"},
+	}
+
+	tests := []struct {
+		name  string
+		mode  updater.UpdateMode
+		want  string
+		kinds []updater.ChangeKind // for A, B, C
+	}{
+		{
+			name:  "all",
+			mode:  updater.ModeReplace | updater.ModeAdd | updater.ModeMoveSection,
+			want:  "C=30\n# [SECTION: db]\nB=20\nA=10\n# [SECTION_END: db]\n",
+			kinds: []updater.ChangeKind{updater.ChangeMoved, updater.ChangeUpdated, updater.ChangeAdded},
+		},
+		{
+			name:  "add missing only",
+			mode:  updater.ModeAdd,
+			want:  "A=1\nC=30\n# [SECTION: db]\nB=2\n# [SECTION_END: db]\n",
+			kinds: []updater.ChangeKind{updater.ChangeSkipped, updater.ChangeSkipped, updater.ChangeAdded},
+		},
+		{
+			name:  "update existing only",
+			mode:  updater.ModeReplace,
+			want:  "A=10\n# [SECTION: db]\nB=20\n# [SECTION_END: db]\n",
+			kinds: []updater.ChangeKind{updater.ChangeUpdated, updater.ChangeUpdated, updater.ChangeSkipped},
+		},
+		{
+			name:  "move without replacing",
+			mode:  updater.ModeMoveSection,
+			want:  "# [SECTION: db]\nB=2\nA=1\n# [SECTION_END: db]\n",
+			kinds: []updater.ChangeKind{updater.ChangeMoved, updater.ChangeSkipped, updater.ChangeSkipped},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changes, err := runUpdates(t, content, updates,
+				updater.SetOrder(updater.OrderUpdates),
+				updater.SetMode(tt.mode),
+			)
+			if err != nil {
+				t.Fatalf("runUpdates() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("runUpdates() = %q, want %q", got, tt.want)
+			}
+
+			for i, update := range updates {
+				change, _ := changes.Get(update.Key)
+				if change.Kind != tt.kinds[i] {
+					t.Errorf("change of %s = %v, want %v", update.Key, change.Kind, tt.kinds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyAndBareKeys(t *testing.T) {
+	const content = "EMPTY=\nSPACES=   \nQUOTED=\"\"\nexport BARE # from CI\nOTHER\n"
+
+	tests := []struct {
+		name    string
+		updates []updater.Update
+		options []updater.Option
+		want    string
+	}{
+		{
+			name: "kept",
+			updates: []updater.Update{
+				{Key: "EMPTY", Value: ""},
+				{Key: "QUOTED", Value: ""},
+			},
+			want: content,
+		},
+		{
+			name: "set values",
+			updates: []updater.Update{
+				{Key: "EMPTY", Value: "1"},
+				{Key: "SPACES", Value: "2"},
+				{Key: "QUOTED", Value: "3"},
+				{Key: "BARE", Value: "4"},
+				{Key: "OTHER", Value: ""},
+			},
+			want: "EMPTY=1\nSPACES=2   \nQUOTED=\"3\"\nexport BARE=4 # from CI\nOTHER=\n",
+		},
+		{
+			name:    "rename bare key",
+			updates: []updater.Update{{Key: "OTHER", Action: updater.ActionRename, NewKey: "RENAMED"}},
+			want:    "EMPTY=\nSPACES=   \nQUOTED=\"\"\nexport BARE # from CI\nRENAMED\n",
+		},
+		{
+			name:    "move bare key without replacing",
+			updates: []updater.Update{{Key: "BARE", Section: "s"}},
+			options: []updater.Option{updater.SetMode(updater.ModeAdd | updater.ModeMoveSection)},
+			want:    "EMPTY=\nSPACES=   \nQUOTED=\"\"\nOTHER\n# [SECTION: s]\nexport BARE # from CI\n\n# [SECTION_END: s]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := runUpdatesWithParser(t, content,
+				[]parser.Option{parser.SetAllowBareKeys(true)},
+				tt.updates,
+				append([]updater.Option{updater.SetOrder(updater.OrderUpdates)}, tt.options...)...,
+			)
+			if err != nil {
+				t.Fatalf("runUpdatesWithParser() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("runUpdatesWithParser() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInlineComments(t *testing.T) {
+	const content = "GREETING=hello world # shown on start\nURL=http://x#frag\n"
+
+	tests := []struct {
+		name    string
+		updates []updater.Update
+		options []updater.Option
+		want    string
+	}{
+		{
+			name: "kept",
+			updates: []updater.Update{
+				{Key: "GREETING", Value: "hello world"},
+				{Key: "URL", Value: "http://x#frag"},
+			},
+			want: content,
+		},
+		{
+			name:    "comment preserved",
+			updates: []updater.Update{{Key: "GREETING", Value: "hi"}},
+			want:    "GREETING=hi # shown on start\nURL=http://x#frag\n",
+		},
+		{
+			name: "values looking like comments quoted",
+			updates: []updater.Update{
+				{Key: "GREETING", Value: "#1"},
+				{Key: "URL", Value: "x #frag"},
+				{Key: "NEW", Value: "a\t#b"},
+			},
+			options: []updater.Option{updater.SetDefaultQuote(0)},
+			want:    "GREETING=\"#1\" # shown on start\nURL=\"x #frag\"\nNEW=\"a\t#b\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]updater.Option{updater.SetOrder(updater.OrderUpdates)}, tt.options...)
+
+			got, err := applyUpdates(t, content, tt.updates, options...)
+			if err != nil {
+				t.Fatalf("applyUpdates() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("applyUpdates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromStreamParseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantKind   error
+		wantLine   int
+		wantColumn int
+	}{
+		{
+			name:       "no key",
+			content:    "A=1\n=2\n",
+			wantKind:   parser.ErrNoKey,
+			wantLine:   2,
+			wantColumn: 1,
+		},
+		{
+			name:       "unterminated quote at EOF",
+			content:    "A=1\nexport B=\"multi\nline\n",
+			wantKind:   parser.ErrUnterminatedQuote,
+			wantLine:   2,
+			wantColumn: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyUpdates(t, tt.content, []updater.Update{{Key: "A", Value: "2"}})
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("applyUpdates() error = %v, want %v", err, tt.wantKind)
+			}
+
+			var parseErr *parser.ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("applyUpdates() error = %v, want ParseError at line %d, column %d", err, tt.wantLine, tt.wantColumn)
+			}
+		})
+	}
+}
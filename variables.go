@@ -0,0 +1,199 @@
+package envfile
+
+import (
+	"strings"
+
+	"github.com/4nd3r5on/go-envfile/common"
+	"github.com/4nd3r5on/go-envfile/interpolate"
+)
+
+// variable is a complete variable definition assembled from parsed lines.
+type variable struct {
+	Key      string
+	Value    string // Value with escape sequences decoded
+	RawValue string // Value as written in the file
+	Quote    byte
+	LineIdx  int  // Index of the definition line
+	Unset    bool // Bare key not found in the environment
+}
+
+// LinesToVariableMap converts an array of ParsedLine into a map of variable key-value pairs.
+// It handles multiline variables by accumulating unterminated values across lines.
+// Bare keys (parser.SetAllowBareKeys) are left out, see LinesToVariableMapWithEnv.
+func LinesToVariableMap(lines []common.ParsedLine) map[string]string {
+	return LinesToVariableMapWithEnv(lines, nil)
+}
+
+// LinesToVariableMapWithEnv works like LinesToVariableMap,
+// but bare keys get their values from lookupEnv (e.g. os.LookupEnv).
+// A bare key lookupEnv doesn't find is left out.
+func LinesToVariableMapWithEnv(
+	lines []common.ParsedLine,
+	lookupEnv func(key string) (string, bool),
+) map[string]string {
+	result := make(map[string]string)
+	for _, v := range collectVariables(lines, lookupEnv) {
+		if v.Unset {
+			delete(result, v.Key)
+
+			continue
+		}
+
+		result[v.Key] = v.Value
+	}
+
+	return result
+}
+
+// LinesToExpandedMap works like LinesToVariableMap, but also expands
+// references to other variables (${VAR}, $VAR, ${VAR:-default}, etc.).
+// See interpolate.ExpandEntries for the resolution rules.
+// Bare keys get their values from the environment if it's enabled
+// (interpolate.SetUseEnv or interpolate.SetLookupEnv), otherwise they are left out.
+func LinesToExpandedMap(lines []common.ParsedLine, options ...interpolate.Option) (map[string]string, error) {
+	cfg := *interpolate.DefaultConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	var lookupEnv func(key string) (string, bool)
+	if cfg.UseEnv {
+		lookupEnv = cfg.LookupEnv
+	}
+
+	vars := collectVariables(lines, lookupEnv)
+
+	entries := make([]interpolate.Entry, 0, len(vars))
+	unset := make(map[string]bool)
+
+	for _, v := range vars {
+		unset[v.Key] = v.Unset
+		if v.Unset {
+			continue
+		}
+
+		entries = append(entries, interpolate.Entry{
+			Key:   v.Key,
+			Value: v.RawValue,
+			Quote: v.Quote,
+		})
+	}
+
+	result, err := interpolate.ExpandEntries(entries, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, isUnset := range unset {
+		if isUnset {
+			delete(result, key)
+		}
+	}
+
+	return result, nil
+}
+
+// collectVariables assembles variable definitions from parsed lines in file order.
+// It handles multiline variables by accumulating unterminated values across lines.
+// Bare keys are looked up with lookupEnv, they are unset if it's nil.
+func collectVariables(lines []common.ParsedLine, lookupEnv func(key string) (string, bool)) []variable {
+	var (
+		result   []variable
+		current  *variable
+		value    strings.Builder
+		rawValue strings.Builder
+	)
+
+	finalize := func() {
+		current.Value = value.String()
+		current.RawValue = rawValue.String()
+		result = append(result, *current)
+		current = nil
+
+		value.Reset()
+		rawValue.Reset()
+	}
+
+	for idx, line := range lines {
+		switch line.Type {
+		case common.LineTypeVar:
+			// If we were building a multiline variable, finalize it
+			if current != nil {
+				finalize()
+			}
+
+			if line.Variable == nil {
+				continue
+			}
+
+			if line.Variable.IsBare {
+				result = append(result, inheritVariable(line.Variable.Key, idx, lookupEnv))
+
+				continue
+			}
+
+			// Start new variable
+			current = &variable{
+				Key:     line.Variable.Key,
+				Quote:   line.Variable.Quote,
+				LineIdx: idx,
+			}
+
+			value.WriteString(line.Variable.Value)
+			rawValue.WriteString(line.Variable.RawValue)
+
+			if line.Variable.IsTerminated {
+				// Single-line variable, store immediately
+				finalize()
+			}
+		case common.LineTypeVal:
+			// Continuation of a multiline variable
+			if current == nil || line.VariableValPart == nil {
+				continue
+			}
+
+			// Add newline before appending next part (preserve multiline format)
+			value.WriteByte('\n')
+			value.WriteString(line.VariableValPart.Value)
+			rawValue.WriteByte('\n')
+			rawValue.WriteString(line.VariableValPart.RawValue)
+
+			if line.VariableValPart.IsTerminated {
+				// Multiline variable ends
+				finalize()
+			}
+		}
+	}
+
+	// Handle case where file ends with unterminated variable
+	if current != nil {
+		finalize()
+	}
+
+	return result
+}
+
+// inheritVariable creates a variable for a bare key with the value from lookupEnv.
+// The value is single-quoted, so it's never expanded.
+func inheritVariable(key string, lineIdx int, lookupEnv func(key string) (string, bool)) variable {
+	var (
+		value string
+		ok    bool
+	)
+
+	if lookupEnv != nil {
+		value, ok = lookupEnv(key)
+	}
+
+	if !ok {
+		return variable{Key: key, LineIdx: lineIdx, Unset: true}
+	}
+
+	return variable{
+		Key:      key,
+		Value:    value,
+		RawValue: value,
+		Quote:    '\'',
+		LineIdx:  lineIdx,
+	}
+}
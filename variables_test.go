@@ -0,0 +1,55 @@
+package envfile_test
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/4nd3r5on/go-envfile"
+	"github.com/4nd3r5on/go-envfile/interpolate"
+	"github.com/4nd3r5on/go-envfile/parser"
+)
+
+func TestLinesToVariableMapBareKeys(t *testing.T) {
+	t.Setenv("ENVFILE_TEST_SET", "from env")
+
+	const content = "EMPTY=\n" +
+		"ENVFILE_TEST_SET\n" +
+		"ENVFILE_TEST_UNSET=default\n" +
+		"ENVFILE_TEST_UNSET\n" +
+		"REF=\"${ENVFILE_TEST_SET}!\"\n"
+
+	lines, err := envfile.Parse(
+		envfile.NewParser(parser.SetAllowBareKeys(true)),
+		bufio.NewScanner(strings.NewReader(content)),
+	)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	want := map[string]string{
+		"EMPTY": "",
+		"REF":   "${ENVFILE_TEST_SET}!",
+	}
+	if got := envfile.LinesToVariableMap(lines); !reflect.DeepEqual(got, want) {
+		t.Errorf("LinesToVariableMap() = %v, want %v", got, want)
+	}
+
+	want["ENVFILE_TEST_SET"] = "from env"
+	if got := envfile.LinesToVariableMapWithEnv(lines, os.LookupEnv); !reflect.DeepEqual(got, want) {
+		t.Errorf("LinesToVariableMapWithEnv() = %v, want %v", got, want)
+	}
+
+	want["REF"] = "from env!"
+
+	got, err := envfile.LinesToExpandedMap(lines, interpolate.SetUseEnv(true))
+	if err != nil {
+		t.Fatalf("LinesToExpandedMap() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LinesToExpandedMap() = %v, want %v", got, want)
+	}
+}